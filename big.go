@@ -0,0 +1,228 @@
+package encdec
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+var errBigLimit = errors.New("encdec: big number exceeds size limit")
+
+//  Big numbers are encoded like ByteSlice with payload of:
+//  Int: sign byte (0 or 1 for negative) and big-endian magnitude without leading zeros, empty for zero,
+//  Rat: sign byte, uvarint length of numerator, numerator and denominator magnitudes,
+//  Float: byte of sign, form and rounding mode, uvarint precision, and for finite non-zero values
+//  varint exponent and magnitude of mantissa without trailing zero bits.
+const (
+	bigZero   = 0
+	bigFinite = 1
+	bigInf    = 2
+
+	//  bigMaxBytes is default limit of magnitude bytes of decoded big number
+	bigMaxBytes = 1 << 12
+)
+
+//  SetBigLimit sets maximum number of magnitude bytes of a decoded big number (precision of big.Float
+//  counts too), larger numbers are decoding errors so that data can not make arithmetic arbitrarily slow
+//  n <= 0 restores default of 4096 bytes
+func (d *Dec) SetBigLimit(n int) {
+	d.bigmax = n
+}
+
+//  bigLimit reports whether n magnitude bytes are within limit of decoder, it sets error otherwise
+func (d *Dec) bigLimit(n int) bool {
+	max := d.bigmax
+	if max <= 0 {
+		max = bigMaxBytes
+	}
+	if n > max {
+		d.err = errBigLimit
+		return false
+	}
+	return true
+}
+
+//  bigBytes decodes payload of a big number
+func (d *Dec) bigBytes() []byte {
+	if !d.tag(KindBytes) {
+		return nil
+	}
+	return d.byteSlice()
+}
+
+//  BigInt encodes a big.Int into buffer
+func (e *Enc) BigInt(x *big.Int) {
+	if e.err != nil {
+		return
+	}
+	if x == nil {
+		e.err = errEncode
+		return
+	}
+	b := append([]byte{0}, x.Bytes()...)
+	if x.Sign() < 0 {
+		b[0] = 1
+	}
+	e.tag(KindBytes)
+	e.bytes(b)
+}
+
+//  BigInt decodes a big.Int from buffer, non-canonical encoding is a decoding error
+func (d *Dec) BigInt() *big.Int {
+	b := d.bigBytes()
+	if d.err != nil {
+		return nil
+	}
+	if len(b) == 0 || b[0] > 1 || (len(b) > 1 && b[1] == 0) || (len(b) == 1 && b[0] == 1) {
+		d.err = errDecode
+		return nil
+	}
+	if !d.bigLimit(len(b) - 1) {
+		return nil
+	}
+	x := new(big.Int).SetBytes(b[1:])
+	if b[0] == 1 {
+		x.Neg(x)
+	}
+	return x
+}
+
+//  BigRat encodes a big.Rat into buffer
+func (e *Enc) BigRat(x *big.Rat) {
+	if e.err != nil {
+		return
+	}
+	if x == nil {
+		e.err = errEncode
+		return
+	}
+	num, den := x.Num().Bytes(), x.Denom().Bytes()
+	b := make([]byte, 1, 1+binary.MaxVarintLen64+len(num)+len(den))
+	if x.Sign() < 0 {
+		b[0] = 1
+	}
+	b = binary.AppendUvarint(b, uint64(len(num)))
+	b = append(append(b, num...), den...)
+	e.tag(KindBytes)
+	e.bytes(b)
+}
+
+//  BigRat decodes a big.Rat from buffer, zero denominator is a decoding error
+func (d *Dec) BigRat() *big.Rat {
+	b := d.bigBytes()
+	if d.err != nil {
+		return nil
+	}
+	if len(b) == 0 || b[0] > 1 {
+		d.err = errDecode
+		return nil
+	}
+	n, i := binary.Uvarint(b[1:])
+	if i <= 0 || n > uint64(len(b)-1-i) {
+		d.err = errDecode
+		return nil
+	}
+	num, den := b[1+i:1+i+int(n)], b[1+i+int(n):]
+	if len(den) == 0 || (len(num) > 0 && num[0] == 0) || den[0] == 0 || (len(num) == 0 && b[0] == 1) {
+		d.err = errDecode
+		return nil
+	}
+	if !d.bigLimit(len(num) + len(den)) {
+		return nil
+	}
+	x := new(big.Rat).SetFrac(new(big.Int).SetBytes(num), new(big.Int).SetBytes(den))
+	if b[0] == 1 {
+		x.Neg(x)
+	}
+	return x
+}
+
+//  BigFloat encodes a big.Float into buffer with its precision and rounding mode
+func (e *Enc) BigFloat(x *big.Float) {
+	if e.err != nil {
+		return
+	}
+	if x == nil {
+		e.err = errEncode
+		return
+	}
+	form := byte(bigFinite)
+	switch {
+	case x.IsInf():
+		form = bigInf
+	case x.Sign() == 0:
+		form = bigZero
+	}
+	b := make([]byte, 1, 1+2*binary.MaxVarintLen64+(x.MinPrec()+7)/8)
+	b[0] = form<<1 | byte(x.Mode())<<3
+	if x.Signbit() {
+		b[0] |= 1
+	}
+	b = binary.AppendUvarint(b, uint64(x.Prec()))
+	if form == bigFinite {
+		// mantissa as an integer of MinPrec bits
+		exp := x.MantExp(nil)
+		m, _ := new(big.Float).SetMantExp(x, int(x.MinPrec())-exp).Int(nil)
+		m.Abs(m)
+		b = binary.AppendVarint(b, int64(exp))
+		b = append(b, m.Bytes()...)
+	}
+	e.tag(KindBytes)
+	e.bytes(b)
+}
+
+//  BigFloat decodes a big.Float from buffer with its precision and rounding mode
+func (d *Dec) BigFloat() *big.Float {
+	b := d.bigBytes()
+	if d.err != nil {
+		return nil
+	}
+	if len(b) == 0 || b[0]>>6 != 0 || big.RoundingMode(b[0]>>3&7) > big.ToPositiveInf {
+		d.err = errDecode
+		return nil
+	}
+	form, neg, mode := b[0]>>1&3, b[0]&1 == 1, big.RoundingMode(b[0]>>3&7)
+	prec, i := binary.Uvarint(b[1:])
+	if i <= 0 || prec > big.MaxPrec {
+		d.err = errDecode
+		return nil
+	}
+	if !d.bigLimit(int((prec + 7) / 8)) {
+		return nil
+	}
+	b = b[1+i:]
+	x := new(big.Float).SetPrec(uint(prec)).SetMode(mode)
+	switch form {
+	case bigZero, bigInf:
+		if len(b) != 0 {
+			d.err = errDecode
+			return nil
+		}
+		if form == bigInf {
+			x.SetInf(neg)
+		} else if neg {
+			x.Neg(x)
+		}
+		return x
+	case bigFinite:
+		exp, i := binary.Varint(b)
+		if i <= 0 || len(b) == i || b[i] == 0 || exp < big.MinExp || exp > big.MaxExp {
+			d.err = errDecode
+			return nil
+		}
+		m := new(big.Int).SetBytes(b[i:])
+		if m.Bit(0) == 0 || uint64(m.BitLen()) > prec {
+			// mantissa has no trailing zeros and fits precision
+			d.err = errDecode
+			return nil
+		}
+		x.SetInt(m)
+		x.SetMantExp(x, int(exp)-m.BitLen())
+		if neg {
+			x.Neg(x)
+		}
+		return x
+	}
+	d.err = errDecode
+	return nil
+}
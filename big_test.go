@@ -0,0 +1,115 @@
+package encdec
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestBigInt(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	for _, tagged := range []bool{false, true} {
+		enc := NewEnc()
+		enc.SetTagged(tagged)
+		ints := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(-255), huge}
+		for _, x := range ints {
+			enc.BigInt(x)
+		}
+		dec := NewDec(enc.Bytes())
+		dec.SetTagged(tagged)
+		for _, x := range ints {
+			if y := dec.BigInt(); y == nil || y.Cmp(x) != 0 {
+				t.Errorf("expected: %v and got: %v, %v", x, y, dec.Error())
+			}
+		}
+	}
+
+	//non-canonical encodings and size limit
+	for _, b := range [][]byte{{}, {2}, {1}, {0, 0, 1}} {
+		enc := NewEnc()
+		enc.ByteSlice(b)
+		dec := NewDec(enc.Bytes())
+		if dec.BigInt(); dec.Error() != errDecode {
+			t.Errorf("%v: expected: %v and got: %v", b, errDecode, dec.Error())
+		}
+	}
+	enc := NewEnc()
+	enc.BigInt(new(big.Int).Lsh(big.NewInt(1), 8*bigMaxBytes))
+	dec := NewDec(enc.Bytes())
+	if dec.BigInt(); dec.Error() != errBigLimit {
+		t.Errorf("expected: %v and got: %v", errBigLimit, dec.Error())
+	}
+	dec = NewDec(enc.Bytes())
+	dec.SetBigLimit(2 * bigMaxBytes)
+	if dec.BigInt(); dec.Error() != nil {
+		t.Errorf("expected: %v and got: %v", nil, dec.Error())
+	}
+}
+
+func TestBigRat(t *testing.T) {
+	rats := []*big.Rat{new(big.Rat), big.NewRat(-1, 3), big.NewRat(1<<40, 7)}
+	enc := NewEnc()
+	for _, x := range rats {
+		enc.BigRat(x)
+	}
+	dec := NewDec(enc.Bytes())
+	for _, x := range rats {
+		if y := dec.BigRat(); y == nil || y.Cmp(x) != 0 {
+			t.Errorf("expected: %v and got: %v, %v", x, y, dec.Error())
+		}
+	}
+	for _, b := range [][]byte{{0, 1, 1}, {0, 2, 1}, {1, 0, 1}, {0, 1, 1, 0}} {
+		enc.Reset()
+		enc.ByteSlice(b)
+		dec := NewDec(enc.Bytes())
+		if dec.BigRat(); dec.Error() != errDecode {
+			t.Errorf("%v: expected: %v and got: %v", b, errDecode, dec.Error())
+		}
+	}
+}
+
+func TestBigFloat(t *testing.T) {
+	pi, _ := new(big.Float).SetPrec(200).SetMode(big.ToZero).SetString("3.14159265358979323846264338327950288419716939937510582097494459")
+	floats := []*big.Float{
+		new(big.Float),
+		new(big.Float).Neg(new(big.Float).SetPrec(10)),
+		new(big.Float).SetInf(true),
+		big.NewFloat(-1.5),
+		big.NewFloat(math.SmallestNonzeroFloat64),
+		new(big.Float).SetMantExp(big.NewFloat(1), big.MaxExp-1),
+		pi,
+	}
+	for _, tagged := range []bool{false, true} {
+		enc := NewEnc()
+		enc.SetTagged(tagged)
+		for _, x := range floats {
+			enc.BigFloat(x)
+		}
+		dec := NewDec(enc.Bytes())
+		dec.SetTagged(tagged)
+		for _, x := range floats {
+			y := dec.BigFloat()
+			if y == nil || y.Cmp(x) != 0 || y.Prec() != x.Prec() || y.Mode() != x.Mode() || y.Signbit() != x.Signbit() || y.IsInf() != x.IsInf() {
+				t.Errorf("expected: %v and got: %v, %v", x, y, dec.Error())
+			}
+		}
+	}
+
+	//precision counts to size limit
+	enc := NewEnc()
+	enc.BigFloat(new(big.Float).SetPrec(8*bigMaxBytes + 1))
+	dec := NewDec(enc.Bytes())
+	if dec.BigFloat(); dec.Error() != errBigLimit {
+		t.Errorf("expected: %v and got: %v", errBigLimit, dec.Error())
+	}
+
+	//mantissa with trailing zero bits or wider than precision
+	for _, b := range [][]byte{{bigFinite << 1, 8, 0, 2}, {bigFinite << 1, 1, 0, 3}, {7 << 3, 0}} {
+		enc.Reset()
+		enc.ByteSlice(b)
+		dec := NewDec(enc.Bytes())
+		if dec.BigFloat(); dec.Error() != errDecode {
+			t.Errorf("%v: expected: %v and got: %v", b, errDecode, dec.Error())
+		}
+	}
+}
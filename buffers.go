@@ -0,0 +1,66 @@
+package encdec
+
+import (
+	"io"
+	"net"
+)
+
+//  encRef is a large byte slice payload kept by reference
+//  it belongs at position off of encoding buffer
+type encRef struct {
+	off int
+	buf []byte
+}
+
+//  SetVectorThreshold makes encoder keep ByteSlice payloads of at least n bytes by reference instead of copying them
+//  referenced slices must not be modified until encoded data is written out, n <= 0 turns the mode off
+func (e *Enc) SetVectorThreshold(n int) {
+	if n < 0 {
+		n = 0
+	}
+	e.vecmin = n
+}
+
+//  Buffers returns encoded data as net.Buffers
+//  small entities are coalesced, referenced payloads are returned as they are
+//  returned buffers alias encoder memory and are valid until the next encoder call
+func (e *Enc) Buffers() net.Buffers {
+	if e.err != nil {
+		return nil
+	}
+	bufs := make(net.Buffers, 0, 2*len(e.refs)+1)
+	last := 0
+	for _, r := range e.refs {
+		if r.off > last {
+			bufs = append(bufs, e.encbuf[last:r.off:r.off])
+		}
+		bufs = append(bufs, r.buf)
+		last = r.off
+	}
+	if last < len(e.encbuf) || len(bufs) == 0 {
+		bufs = append(bufs, e.encbuf[last:len(e.encbuf):len(e.encbuf)])
+	}
+	return bufs
+}
+
+//  writeBuffers writes encoded data using vectored I/O when w supports it (e.g. *net.TCPConn)
+func (e *Enc) writeBuffers(w io.Writer) (int64, error) {
+	bufs := e.Buffers()
+	var n int64
+	n, e.err = bufs.WriteTo(w)
+	if e.err == nil && n < int64(e.Len()) {
+		e.err = errEncode
+	}
+	return n, e.err
+}
+
+//  appendFlat appends encoded data with referenced payloads gathered in place
+func (e *Enc) appendFlat(dst []byte) []byte {
+	last := 0
+	for _, r := range e.refs {
+		dst = append(dst, e.encbuf[last:r.off]...)
+		dst = append(dst, r.buf...)
+		last = r.off
+	}
+	return append(dst, e.encbuf[last:]...)
+}
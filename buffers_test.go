@@ -0,0 +1,54 @@
+package encdec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncBuffers(t *testing.T) {
+	blob1 := bytes.Repeat([]byte{1}, 100)
+	blob2 := bytes.Repeat([]byte{2}, 200)
+	encode := func(e *Enc) {
+		e.Uint64(1)
+		e.ByteSlice(blob1)
+		e.ByteSlice([]byte{3, 4})
+		e.ByteSlice(blob2)
+		e.ByteSlice(blob1)
+		e.Int64(-1)
+	}
+	plain := NewEnc()
+	encode(plain)
+	enc := NewEnc()
+	enc.SetVectorThreshold(100)
+	encode(enc)
+
+	if !bytes.Equal(enc.Bytes(), plain.Bytes()) || enc.Len() != plain.Len() {
+		t.Fatalf("expected: %v and got: %v", plain.Bytes(), enc.Bytes())
+	}
+	bufs := enc.Buffers()
+	if len(bufs) != 7 {
+		t.Fatalf("expected: 7 buffers and got: %v", len(bufs))
+	}
+	for i, b := range [][]byte{blob1, blob2, blob1} {
+		if &bufs[2*i+1][0] != &b[0] {
+			t.Errorf("buffer %v is not referenced", 2*i+1)
+		}
+	}
+	if !bytes.Equal(bytes.Join(bufs, nil), plain.Bytes()) {
+		t.Error("joined buffers differ")
+	}
+	if !bytes.Equal(enc.AppendTo(nil), plain.Bytes()) {
+		t.Error("AppendTo differs")
+	}
+
+	var w bytes.Buffer
+	n, err := enc.WriteTo(&w)
+	if err != nil || n != int64(plain.Len()) || !bytes.Equal(w.Bytes(), plain.Bytes()) {
+		t.Errorf("WriteTo: expected: %v and got: %v, %v", plain.Len(), n, err)
+	}
+
+	buf := enc.Detach()
+	if !bytes.Equal(buf, plain.Bytes()) || enc.Len() != 0 || len(enc.Buffers()) != 1 {
+		t.Error("Detach failed")
+	}
+}
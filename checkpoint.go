@@ -0,0 +1,51 @@
+package encdec
+
+//  Checkpoint is an encoding position token returned by Enc.Checkpoint
+type Checkpoint struct {
+	lng     int
+	refs    int
+	reflen  int
+	gen     int
+	records int
+	seq     int
+	fields  int
+	shared  int
+	dict    int
+}
+
+//  Checkpoint returns actual end of encoded data, encoder can be rolled back to it with Rollback
+func (e *Enc) Checkpoint() Checkpoint {
+	cp := Checkpoint{lng: len(e.encbuf), refs: len(e.refs), reflen: e.reflen, gen: e.gen, records: len(e.records), fields: len(e.fields), shared: len(e.shared), dict: e.dictLen()}
+	if cp.records > 0 {
+		cp.seq = e.records[cp.records-1].seq
+	}
+	return cp
+}
+
+//  Rollback discards data encoded after checkpoint cp and clears encoding error
+//  it allows to attempt optional sections, e.g. skip a record that fails to marshal
+//  checkpoint taken before Reset or Detach, or inside a record that has ended since, is an encoding error
+func (e *Enc) Rollback(cp Checkpoint) {
+	if cp.gen != e.gen || cp.lng > len(e.encbuf) || cp.refs > len(e.refs) || cp.records > len(e.records) || cp.fields > len(e.fields) || cp.shared > len(e.shared) ||
+		(cp.records > 0 && e.records[cp.records-1].seq != cp.seq) {
+		e.err = errEncode
+		return
+	}
+	e.records = e.records[:cp.records]
+	e.fields = e.fields[:cp.fields]
+	e.err = nil
+	e.encbuf = e.encbuf[:cp.lng]
+	for i := cp.refs; i < len(e.refs); i++ {
+		// do not keep rolled back payloads alive
+		e.refs[i] = encRef{}
+	}
+	e.refs = e.refs[:cp.refs]
+	e.reflen = cp.reflen
+	for i := cp.shared; i < len(e.shared); i++ {
+		// objects encoded after checkpoint get new ids
+		delete(e.sharedIDs, e.shared[i])
+		e.shared[i] = nil
+	}
+	e.shared = e.shared[:cp.shared]
+	e.rollbackDict(cp.dict)
+}
@@ -0,0 +1,41 @@
+package encdec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncCheckpointRollback(t *testing.T) {
+	blob := bytes.Repeat([]byte{1}, 100)
+	exp := NewEnc()
+	exp.Uint64(1)
+	exp.ByteSlice(blob)
+	exp.Uint64(3)
+
+	enc := NewEnc()
+	enc.SetVectorThreshold(50)
+	enc.Uint64(1)
+	enc.ByteSlice(blob)
+	cp := enc.Checkpoint()
+	//failing optional section
+	enc.Uint64(2)
+	enc.ByteSlice(blob)
+	enc.Marshaler(&testType{C: "x"})
+	enc.ByteSlice(nil)
+	if enc.Error() != errEncode {
+		t.Fatalf("expected: %v and got: %v", errEncode, enc.Error())
+	}
+	enc.Rollback(cp)
+	enc.Uint64(3)
+	if enc.Error() != nil || !bytes.Equal(enc.Bytes(), exp.Bytes()) || enc.Len() != exp.Len() || len(enc.Buffers()) != 3 {
+		t.Errorf("expected: %v and got: %v, %v", exp.Bytes(), enc.Bytes(), enc.Error())
+	}
+
+	//stale checkpoint
+	enc.Reset()
+	enc.ByteSlice(blob)
+	enc.Rollback(cp)
+	if enc.Error() != errEncode {
+		t.Errorf("expected: %v and got: %v", errEncode, enc.Error())
+	}
+}
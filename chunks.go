@@ -0,0 +1,89 @@
+package encdec
+
+import (
+	"io"
+)
+
+//  NewDecChunks creates decoder reading encoded data from a chain of buffers (e.g. net.Buffers)
+//  entities fitting in a single chunk are decoded without copying, only entities straddling chunk boundary are copied
+func NewDecChunks(chunks [][]byte) (d *Dec) {
+	if chunks == nil {
+		return NewDec(nil)
+	}
+	d = NewDec([]byte{})
+	// chunks read by ReadFrom must not be appended into spare capacity of caller's slice
+	d.orig = chunks[:len(chunks):len(chunks)]
+	d.resetChunks()
+	return
+}
+
+//  resetChunks rewinds decoder to the first chunk
+func (d *Dec) resetChunks() {
+	d.decbuf = []byte{}
+	d.chunks = d.orig
+	d.skip = 0
+	d.base = 0
+	d.rest = 0
+	for _, c := range d.chunks {
+		d.rest += len(c)
+	}
+}
+
+//  need makes next n bytes of undecoded data contiguous in decoding buffer if possible
+func (d *Dec) need(n int) {
+	if len(d.decbuf)-d.i >= n || len(d.chunks) == 0 || d.i < 0 {
+		return
+	}
+	d.stitch(n)
+}
+
+//  stitch moves decoding buffer to the next chunk, or joins the rest of actual chunk
+//  with the beginning of following ones when an entity straddles chunk boundary
+func (d *Dec) stitch(n int) {
+	for d.i >= len(d.decbuf) && len(d.chunks) > 0 {
+		d.base += len(d.decbuf)
+		d.decbuf = d.chunks[0][d.skip:]
+		d.rest -= len(d.decbuf)
+		d.chunks = d.chunks[1:]
+		d.skip = 0
+		d.i = 0
+	}
+	if len(d.decbuf)-d.i >= n || len(d.chunks) == 0 {
+		return
+	}
+	if avail := len(d.decbuf) - d.i + d.rest; n > avail {
+		// corrupted length, do not allocate more than there is
+		n = avail
+	}
+	buf := make([]byte, 0, n)
+	buf = append(buf, d.decbuf[d.i:]...)
+	for len(buf) < n && len(d.chunks) > 0 {
+		c := d.chunks[0][d.skip:]
+		take := n - len(buf)
+		if take >= len(c) {
+			take = len(c)
+			d.chunks = d.chunks[1:]
+			d.skip = 0
+		} else {
+			d.skip += take
+		}
+		buf = append(buf, c[:take]...)
+		d.rest -= take
+	}
+	d.base += d.i
+	d.decbuf = buf
+	d.i = 0
+}
+
+//  readChunk reads data from a io.Reader into a new chunk appended after the pending ones
+func (d *Dec) readChunk(r io.Reader, hint int64) (int64, error) {
+	t := Dec{decbuf: []byte{}, limit: d.limit}
+	n, err := t.readFrom(r, hint)
+	if len(t.decbuf) > 0 {
+		d.orig = append(d.orig, t.decbuf)
+		d.chunks = d.orig[len(d.orig)-len(d.chunks)-1:]
+		d.rest += len(t.decbuf)
+	}
+	d.err = err
+	return n, err
+}
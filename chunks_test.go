@@ -0,0 +1,93 @@
+package encdec
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDecChunks(t *testing.T) {
+	ti := time.Now()
+	blob := bytes.Repeat([]byte{7}, 50)
+	enc := NewEnc()
+	for i := 0; i < 20; i++ {
+		enc.Uint64(uint64(i) << 40)
+		enc.Int64(-int64(i))
+		enc.Float64(float64(i) / 3)
+		enc.ByteSlice(blob[:i])
+		enc.Marshaler(ti)
+	}
+	data := enc.Bytes()
+
+	for _, size := range []int{1, 2, 3, 7, 16, 64, len(data)} {
+		var chunks net.Buffers
+		for b := data; len(b) > 0; {
+			l := size
+			if l > len(b) {
+				l = len(b)
+			}
+			chunks = append(chunks, b[:l], []byte{})
+			b = b[l:]
+		}
+		dec := NewDecChunks(chunks)
+		if dec.Len() != len(data) {
+			t.Fatalf("size %v: expected: %v and got: %v", size, len(data), dec.Len())
+		}
+		for rep := 0; rep < 2; rep++ {
+			for i := 0; i < 20; i++ {
+				x, y, f, b := dec.Uint64(), dec.Int64(), dec.Float64(), dec.ByteSlice()
+				var td time.Time
+				dec.Unmarshaler(&td)
+				if dec.Error() != nil || x != uint64(i)<<40 || y != -int64(i) || f != float64(i)/3 || !bytes.Equal(b, blob[:i]) || !td.Equal(ti) {
+					t.Fatalf("size %v, entity %v: decoding failed: %v", size, i, dec.Error())
+				}
+			}
+			if dec.Len() != 0 || dec.Pos() != len(data) {
+				t.Errorf("size %v: expected: %v and got: %v", size, len(data), dec.Pos())
+			}
+			dec.Uint64()
+			if dec.Error() != errNoDecData {
+				t.Errorf("expected: %v and got: %v", errNoDecData, dec.Error())
+			}
+			dec.Reset()
+		}
+	}
+
+	//zero copy within a chunk
+	enc.Reset()
+	enc.ByteSlice(blob)
+	enc.ByteSlice(blob)
+	data = enc.Bytes()
+	dec := NewDecChunks([][]byte{data[:len(data)/2], data[len(data)/2:]})
+	if b := dec.ByteSlice(); &b[0] != &data[2] {
+		t.Error("expected zero copy slice")
+	}
+	if b := dec.ByteSlice(); !bytes.Equal(b, blob) || dec.Error() != nil {
+		t.Errorf("expected: %v and got: %v", blob, b)
+	}
+
+	//reading appends a chunk, not into slice of caller
+	chunks := make([][]byte, 1, 2)
+	chunks[0] = data[:10]
+	dec = NewDecChunks(chunks)
+	if _, err := dec.ReadFrom(bytes.NewReader(data[10:])); err != nil || dec.Len() != len(data) {
+		t.Fatalf("expected: %v and got: %v, %v", len(data), dec.Len(), err)
+	}
+	if chunks[:2][1] != nil {
+		t.Errorf("expected: %v and got: %v", nil, chunks[:2][1])
+	}
+	if !bytes.Equal(dec.ByteSlice(), blob) || !bytes.Equal(dec.ByteSlice(), blob) || dec.Error() != nil {
+		t.Error("decoding of read chunk failed")
+	}
+
+	//truncated data
+	dec = NewDecChunks([][]byte{data[:10], data[10:20]})
+	dec.ByteSlice()
+	if dec.Error() != errDecodeNotEnoughtData {
+		t.Errorf("expected: %v and got: %v", errDecodeNotEnoughtData, dec.Error())
+	}
+	if NewDecChunks(nil).Error() != errDecode {
+		t.Error("expected: error got: nil")
+	}
+}
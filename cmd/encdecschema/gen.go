@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mrkovec/encdec/schema"
+)
+
+//  annotation marks struct types emitted by gen
+const annotation = "encdec:schema"
+
+//  gen prints schema of struct types of package in given directory,
+//  the same one as schema.FromType derives at runtime
+func gen(args []string) int {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	names := fs.String("type", "", "comma separated struct types, default are types annotated by //"+annotation)
+	fs.Parse(args)
+	dir := "."
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		dir = fs.Arg(0)
+	default:
+		usage()
+	}
+	var roots []string
+	if *names != "" {
+		roots = strings.Split(*names, ",")
+	}
+	s, err := generate(dir, roots)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Print(s)
+	return 0
+}
+
+//  generate returns schema of struct types roots of package in dir, or of its annotated types if roots are empty
+func generate(dir string, roots []string) (*schema.Schema, error) {
+	pkg, annotated, err := loadPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
+		roots = annotated
+	}
+	b := &builder{types: make(map[*types.Named]*schema.Message), names: make(map[string]bool)}
+	for _, name := range roots {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, errors.New("encdecschema: type " + name + " not found")
+		}
+		if _, err = b.typ(obj.Type()); err != nil {
+			return nil, err
+		}
+	}
+	// printed schema is parsed back to validate it
+	return schema.Parse((&schema.Schema{Messages: b.messages}).String())
+}
+
+//  loadPackage type checks package in dir and returns names of its annotated types
+func loadPackage(dir string) (*types.Package, []string, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	var annotated []string
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if doc != nil && strings.Contains(commentText(doc), annotation) {
+					annotated = append(annotated, ts.Name.Name)
+				}
+			}
+		}
+	}
+	sort.Strings(annotated)
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check(bp.ImportPath, fset, files, nil)
+	return pkg, annotated, err
+}
+
+//  commentText returns raw text of comments including //directive lines which ast.CommentGroup.Text omits
+func commentText(doc *ast.CommentGroup) string {
+	var sb strings.Builder
+	for _, c := range doc.List {
+		sb.WriteString(c.Text)
+	}
+	return sb.String()
+}
+
+//  builder collects messages of struct types following schema.FromType rules
+type builder struct {
+	messages []*schema.Message
+	types    map[*types.Named]*schema.Message
+	names    map[string]bool
+}
+
+func (b *builder) message(t *types.Named, st *types.Struct) (*schema.Message, error) {
+	if m := b.types[t]; m != nil {
+		return m, nil
+	}
+	name := t.Obj().Name()
+	if b.names[name] {
+		return nil, errors.New("encdecschema: duplicate message " + name)
+	}
+	m := &schema.Message{Name: name}
+	b.types[t], b.names[name] = m, true
+	b.messages = append(b.messages, m)
+	fields := make([]schema.StructField, st.NumFields())
+	for i := range fields {
+		sf := st.Field(i)
+		fields[i] = schema.StructField{
+			Name:      sf.Name(),
+			Exported:  sf.Exported(),
+			Tag:       reflect.StructTag(st.Tag(i)),
+			RawFields: rawFields(sf.Type()),
+			Type:      func() (*schema.Type, error) { return b.typ(sf.Type()) },
+		}
+	}
+	if err := schema.BuildMessage(m, fields); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (b *builder) typ(t types.Type) (*schema.Type, error) {
+	t = types.Unalias(t)
+	if p, ok := t.(*types.Pointer); ok && isTime(p.Elem()) || isTime(t) {
+		return &schema.Type{Kind: schema.Time}, nil
+	}
+	if marshaler(t) {
+		return &schema.Type{Kind: schema.Bytes}, nil
+	}
+	if p, ok := t.(*types.Pointer); ok {
+		if _, ok = p.Elem().Underlying().(*types.Struct); ok {
+			t = p.Elem()
+		}
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		nt, ok := t.(*types.Named)
+		if !ok {
+			return nil, errors.New("encdecschema: anonymous struct " + t.String())
+		}
+		m, err := b.message(nt, u)
+		if err != nil {
+			return nil, err
+		}
+		return &schema.Type{Kind: schema.MessageRef, Name: m.Name}, nil
+	case *types.Basic:
+		switch info := u.Info(); {
+		case info&types.IsBoolean != 0:
+			return &schema.Type{Kind: schema.Bool}, nil
+		case info&types.IsUnsigned != 0:
+			return &schema.Type{Kind: schema.Uint64}, nil
+		case info&types.IsInteger != 0:
+			return &schema.Type{Kind: schema.Int64}, nil
+		case info&types.IsFloat != 0:
+			return &schema.Type{Kind: schema.Float64}, nil
+		case info&types.IsString != 0:
+			return &schema.Type{Kind: schema.String}, nil
+		}
+	case *types.Slice:
+		if e, ok := u.Elem().Underlying().(*types.Basic); ok && e.Kind() == types.Byte {
+			return &schema.Type{Kind: schema.Bytes}, nil
+		}
+		elem, err := b.typ(u.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema.Type{Kind: schema.List, Elem: elem}, nil
+	case *types.Array:
+		elem, err := b.typ(u.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema.Type{Kind: schema.List, Elem: elem}, nil
+	case *types.Map:
+		key, err := b.typ(u.Key())
+		if err != nil {
+			return nil, err
+		}
+		elem, err := b.typ(u.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema.Type{Kind: schema.Map, Key: key, Elem: elem}, nil
+	}
+	return nil, errors.New("encdecschema: unsupported type " + t.String())
+}
+
+func isTime(t types.Type) bool {
+	nt, ok := t.(*types.Named)
+	return ok && nt.Obj().Pkg() != nil && nt.Obj().Pkg().Path() == "time" && nt.Obj().Name() == "Time"
+}
+
+//  marshaler reports whether t or pointer to it implements encoding.BinaryMarshaler
+func marshaler(t types.Type) bool {
+	for _, mt := range []types.Type{t, types.NewPointer(t)} {
+		sel := types.NewMethodSet(mt).Lookup(nil, "MarshalBinary")
+		if sel == nil {
+			continue
+		}
+		sig := sel.Type().(*types.Signature)
+		if sig.Params().Len() == 0 && sig.Results().Len() == 2 && sig.Results().At(0).Type().String() == "[]byte" {
+			return true
+		}
+	}
+	return false
+}
+
+//  rawFields reports whether t is []encdec.RawField
+func rawFields(t types.Type) bool {
+	st, ok := types.Unalias(t).(*types.Slice)
+	if !ok {
+		return false
+	}
+	nt, ok := types.Unalias(st.Elem()).(*types.Named)
+	return ok && nt.Obj().Pkg() != nil && nt.Obj().Pkg().Path() == "github.com/mrkovec/encdec" && nt.Obj().Name() == "RawField"
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mrkovec/encdec/cmd/encdecschema/testdata/models"
+	"github.com/mrkovec/encdec/schema"
+)
+
+func TestGenerate(t *testing.T) {
+	expected, err := schema.FromType(reflect.TypeOf(models.Account{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, roots := range [][]string{nil, {"Account"}} {
+		s, err := generate("testdata/models", roots)
+		if err != nil || s.String() != expected.String() {
+			t.Errorf("expected: %v and got: %v, %v", expected, s, err)
+		}
+	}
+	for _, root := range []string{"Missing", "Legacy"} {
+		if _, err = generate("testdata/models", []string{root}); err == nil {
+			t.Errorf("%v: expected: error got: nil", root)
+		}
+	}
+	if _, err = schema.FromType(reflect.TypeOf(models.Legacy{})); err == nil {
+		t.Error("expected: error got: nil")
+	}
+}
@@ -0,0 +1,94 @@
+/*
+  Command encdecschema works with encdec schema definitions.
+
+  Usage:
+
+	encdecschema check [-require both|backward|forward] old.schema new.schema
+	encdecschema gen [-type T1,T2] [package dir]
+
+  check compares two versions of a schema and writes a JSON report (schema.Report) to stdout.
+  It exits with status 1 when required compatibility is broken, so it can serve as a CI gate,
+  and with status 2 on invalid usage or schema.
+
+  gen prints schema of struct types of a package, by default of those annotated by //encdec:schema comment:
+
+	//encdec:schema
+	type User struct {
+		Name    string `encdec:"name,1,required"`
+		Created time.Time
+	}
+
+  Types are mapped the way schema.FromType maps them at runtime.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mrkovec/encdec/schema"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "check":
+		os.Exit(check(os.Args[2:]))
+	case "gen":
+		os.Exit(gen(os.Args[2:]))
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: encdecschema check [-require both|backward|forward] old.schema new.schema")
+	fmt.Fprintln(os.Stderr, "       encdecschema gen [-type T1,T2] [package dir]")
+	os.Exit(2)
+}
+
+func check(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	require := fs.String("require", "both", "required compatibility: both, backward (new reads old) or forward (old reads new)")
+	fs.Parse(args)
+	if fs.NArg() != 2 || (*require != "both" && *require != "backward" && *require != "forward") {
+		usage()
+	}
+	old, err := load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	new, err := load(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	r := schema.Check(old, new)
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	if err = out.Encode(r); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if (*require != "forward" && !r.Backward) || (*require != "backward" && !r.Forward) {
+		return 1
+	}
+	return 0
+}
+
+func load(name string) (*schema.Schema, error) {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	s, err := schema.Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return s, nil
+}
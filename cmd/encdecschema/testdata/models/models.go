@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/mrkovec/encdec"
+)
+
+type ID [2]uint32
+
+func (id ID) MarshalBinary() ([]byte, error) {
+	return []byte{byte(id[0]), byte(id[1])}, nil
+}
+
+//encdec:schema
+type Account struct {
+	ID       ID
+	URLPath  string `encdec:"path,3,required"`
+	Limit    int64  `encdec:",6"`
+	Owners   []*Owner
+	Labels   map[string][]byte
+	Settings Settings
+	Skipped  int `encdec:"-"`
+	Unknown  []encdec.RawField `encdec:",unknown"`
+	hidden   int
+}
+
+type Owner struct {
+	Name    string
+	Created *time.Time
+	Score   float32
+	Parent  *Owner
+}
+
+type Settings struct {
+	_     struct{} `encdec:",sparse"`
+	Port  uint16
+	Hosts []string `encdec:"hosts,9"`
+	On    bool
+}
+
+type Legacy struct {
+	Name    string
+	Unknown []string `encdec:",unknown"`
+}
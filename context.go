@@ -0,0 +1,180 @@
+package encdec
+
+import (
+	"bufio"
+	"context"
+	"encoding"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+//  ctxWriteBlock is the biggest piece of data written between two cancellation checks
+const ctxWriteBlock = 64 << 10
+
+//  WriteToContext writes encoded data to w like WriteTo, checking ctx between written blocks
+//  deadline of ctx is applied to w if it supports SetWriteDeadline (e.g. net.Conn), so a stuck write is interrupted too
+//  when ctx is done, ctx.Err() becomes the sticky encoding error
+func (e *Enc) WriteToContext(ctx context.Context, w io.Writer) (int64, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	if e.err = ctx.Err(); e.err != nil {
+		return 0, e.err
+	}
+	defer watchDeadline(ctx, w, true)()
+	var (
+		n int64
+		m int
+	)
+	for _, b := range e.Buffers() {
+		for len(b) > 0 {
+			if e.err = ctx.Err(); e.err != nil {
+				return n, e.err
+			}
+			blk := b
+			if len(blk) > ctxWriteBlock {
+				blk = blk[:ctxWriteBlock]
+			}
+			m, e.err = w.Write(blk)
+			n += int64(m)
+			if e.err != nil {
+				e.err = ctxError(ctx, e.err)
+				return n, e.err
+			}
+			if m < len(blk) {
+				e.err = errEncode
+				return n, e.err
+			}
+			b = b[m:]
+		}
+	}
+	return n, nil
+}
+
+//  ReadFromContext reads data from r like ReadFrom, checking ctx between reads
+//  deadline of ctx is applied to r if it supports SetReadDeadline (e.g. net.Conn), so a stuck read is interrupted too
+//  when ctx is done, ctx.Err() becomes the sticky decoding error
+func (d *Dec) ReadFromContext(ctx context.Context, r io.Reader) (int64, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.err = ctx.Err(); d.err != nil {
+		return 0, d.err
+	}
+	defer watchDeadline(ctx, r, false)()
+	return d.readFrom(ctxReader{ctx: ctx, r: r}, readSizeHint(r))
+}
+
+//  StreamReader decodes records written by Enc.Marshaler one by one from a io.Reader
+//  without reading the whole stream into memory
+type StreamReader struct {
+	ctx context.Context
+	r   io.Reader
+	br  *bufio.Reader
+	err error
+}
+
+//  NewStreamReader creates a reader of records from r, reading is stopped when ctx is done
+func NewStreamReader(ctx context.Context, r io.Reader) *StreamReader {
+	return &StreamReader{
+		ctx: ctx,
+		r:   r,
+		br:  bufio.NewReader(ctxReader{ctx: ctx, r: r})}
+}
+
+//  Unmarshaler reads next record and decodes it into x
+//  io.EOF is the sticky error after the last record, ctx.Err() when ctx is done
+func (s *StreamReader) Unmarshaler(x encoding.BinaryUnmarshaler) {
+	if s.err != nil {
+		return
+	}
+	if x == nil {
+		s.err = errDecode
+		return
+	}
+	var data []byte
+	if data, s.err = s.Next(); s.err == nil {
+		s.err = x.UnmarshalBinary(data)
+	}
+}
+
+//  Next reads payload of next record without decoding it
+func (s *StreamReader) Next() ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.err = s.ctx.Err(); s.err != nil {
+		return nil, s.err
+	}
+	stop := watchDeadline(s.ctx, s.r, false)
+	var data []byte
+	data, s.err = readRecord(s.br)
+	stop()
+	if s.err != nil {
+		s.err = ctxError(s.ctx, s.err)
+	}
+	return data, s.err
+}
+
+//  Error returns reading error if any
+func (s StreamReader) Error() error {
+	return s.err
+}
+
+//  ctxReader fails reads once its context is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := c.r.Read(p)
+	if err != nil {
+		err = ctxError(c.ctx, err)
+	}
+	return n, err
+}
+
+//  ctxError replaces I/O error caused by done ctx with ctx.Err()
+func ctxError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if dl, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) && !time.Now().Before(dl) {
+		// connection deadline may expire a moment before ctx notices
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+//  watchDeadline applies deadline and cancellation of ctx to x if it supports deadlines
+//  returned function disarms it
+func watchDeadline(ctx context.Context, x interface{}, write bool) func() {
+	var set func(time.Time) error
+	if write {
+		if c, ok := x.(interface{ SetWriteDeadline(time.Time) error }); ok {
+			set = c.SetWriteDeadline
+		}
+	} else {
+		if c, ok := x.(interface{ SetReadDeadline(time.Time) error }); ok {
+			set = c.SetReadDeadline
+		}
+	}
+	if set == nil || ctx.Done() == nil {
+		return func() {}
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		set(dl)
+	}
+	// a deadline in the past interrupts blocked I/O
+	stop := context.AfterFunc(ctx, func() { set(time.Unix(1, 0)) })
+	return func() {
+		stop()
+		set(time.Time{})
+	}
+}
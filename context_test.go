@@ -0,0 +1,87 @@
+package encdec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEncDecContext(t *testing.T) {
+	enc := NewEnc()
+	enc.ByteSlice(make([]byte, 3*ctxWriteBlock))
+	enc.Uint64(1)
+
+	//plain round trip
+	var buf bytes.Buffer
+	if n, err := enc.WriteToContext(context.Background(), &buf); err != nil || n != int64(enc.Len()) {
+		t.Fatalf("expected: %v and got: %v, %v", enc.Len(), n, err)
+	}
+	dec := NewDec([]byte{})
+	if n, err := dec.ReadFromContext(context.Background(), &buf); err != nil || n != int64(enc.Len()) {
+		t.Fatalf("expected: %v and got: %v, %v", enc.Len(), n, err)
+	}
+	if len(dec.ByteSlice()) != 3*ctxWriteBlock || dec.Uint64() != 1 || dec.Error() != nil {
+		t.Error("decoding failed")
+	}
+
+	//stuck write to a net.Conn is interrupted
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		io.ReadFull(c2, make([]byte, 10))
+		cancel()
+	}()
+	if _, err := enc.WriteToContext(ctx, c1); err != context.Canceled || enc.Error() != context.Canceled {
+		t.Errorf("expected: %v and got: %v", context.Canceled, err)
+	}
+
+	//stuck read from a net.Conn hits ctx deadline
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	dec = NewDec([]byte{})
+	if _, err := dec.ReadFromContext(ctx, c2); err != context.DeadlineExceeded || dec.Error() != context.DeadlineExceeded {
+		t.Errorf("expected: %v and got: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestStreamReader(t *testing.T) {
+	enc := NewEnc()
+	for i := 0; i < 100; i++ {
+		v := newTestType()
+		v.A = i
+		enc.Marshaler(&v)
+	}
+	sr := NewStreamReader(context.Background(), bytes.NewReader(enc.Bytes()))
+	for i := 0; i < 100; i++ {
+		var v testType
+		sr.Unmarshaler(&v)
+		if sr.Error() != nil || v.A != i {
+			t.Fatalf("expected: %v and got: %v, %v", i, v.A, sr.Error())
+		}
+	}
+	if _, err := sr.Next(); err != io.EOF {
+		t.Errorf("expected: %v and got: %v", io.EOF, err)
+	}
+
+	//cancellation of a blocked stream
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	sr = NewStreamReader(ctx, c2)
+	go func() {
+		c1.Write(enc.Bytes()[:10])
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	var v testType
+	sr.Unmarshaler(&v)
+	if sr.Error() != context.Canceled {
+		t.Errorf("expected: %v and got: %v", context.Canceled, sr.Error())
+	}
+}
@@ -0,0 +1,229 @@
+package encdec
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var errDecimal = errors.New("encdec: invalid decimal")
+
+//  Decimal is an exact fixed-point decimal number, coefficient×10^-scale
+//  zero value is 0, coefficients not fitting int64 are kept as big.Int
+//  Enc.Decimal encodes normalized value, so 1.50 and 1.5 encode identically and decode as 1.5
+type Decimal struct {
+	coef  int64
+	big   *big.Int // coefficient not fitting int64, nil otherwise
+	scale int32
+}
+
+//  decimalMaxZeros is the most padding zeros String writes before it switches to exponent notation
+const decimalMaxZeros = 32
+
+//  NewDecimal returns decimal coef×10^-scale, e.g. NewDecimal(150, 2) is 1.50
+func NewDecimal(coef int64, scale int32) Decimal {
+	return Decimal{coef: coef, scale: scale}
+}
+
+//  NewDecimalBig returns decimal coef×10^-scale with arbitrary coefficient
+func NewDecimalBig(coef *big.Int, scale int32) Decimal {
+	if coef.IsInt64() {
+		return Decimal{coef: coef.Int64(), scale: scale}
+	}
+	return Decimal{big: new(big.Int).Set(coef), scale: scale}
+}
+
+//  ParseDecimal parses decimal in plain or exponent notation, e.g. "-12.50" or "1.25e-3"
+//  scale of the result follows the notation, "12.50" has coefficient 1250 and scale 2
+func ParseDecimal(s string) (Decimal, error) {
+	mant, exp := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		if exp, err = strconv.ParseInt(s[i+1:], 10, 32); err != nil {
+			return Decimal{}, errDecimal
+		}
+		mant = s[:i]
+	}
+	neg := false
+	if mant != "" && (mant[0] == '-' || mant[0] == '+') {
+		neg, mant = mant[0] == '-', mant[1:]
+	}
+	digits, frac := mant, ""
+	if i := strings.IndexByte(mant, '.'); i >= 0 {
+		digits, frac = mant[:i], mant[i+1:]
+	}
+	digits += frac
+	if digits == "" {
+		return Decimal{}, errDecimal
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return Decimal{}, errDecimal
+		}
+	}
+	scale := int64(len(frac)) - exp
+	if scale < math.MinInt32 || scale > math.MaxInt32 {
+		return Decimal{}, errDecimal
+	}
+	if neg {
+		digits = "-" + digits
+	}
+	if coef, err := strconv.ParseInt(digits, 10, 64); err == nil {
+		return Decimal{coef: coef, scale: int32(scale)}, nil
+	}
+	coef, _ := new(big.Int).SetString(digits, 10)
+	return NewDecimalBig(coef, int32(scale)), nil
+}
+
+//  Coef returns coefficient of decimal
+func (x Decimal) Coef() *big.Int {
+	if x.big != nil {
+		return new(big.Int).Set(x.big)
+	}
+	return big.NewInt(x.coef)
+}
+
+//  Scale returns number of decimal digits of coefficient after decimal point, negative for multiples of 10^-scale
+func (x Decimal) Scale() int32 {
+	return x.scale
+}
+
+//  Sign returns -1, 0 or 1 according to sign of decimal
+func (x Decimal) Sign() int {
+	switch {
+	case x.big != nil:
+		return x.big.Sign()
+	case x.coef < 0:
+		return -1
+	case x.coef > 0:
+		return 1
+	}
+	return 0
+}
+
+//  Normalize returns canonical form of decimal with no trailing zeros in coefficient, zero has scale 0
+func (x Decimal) Normalize() Decimal {
+	if x.big == nil {
+		if x.coef == 0 {
+			return Decimal{}
+		}
+		for x.coef%10 == 0 && x.scale > math.MinInt32 {
+			x.coef /= 10
+			x.scale--
+		}
+		return x
+	}
+	c, q, m, ten := new(big.Int).Set(x.big), new(big.Int), new(big.Int), big.NewInt(10)
+	for x.scale > math.MinInt32 {
+		if q.QuoRem(c, ten, m); m.Sign() != 0 {
+			break
+		}
+		c, q = q, c
+		x.scale--
+	}
+	return NewDecimalBig(c, x.scale)
+}
+
+//  Equal reports whether x and y are the same amount regardless of their scales
+func (x Decimal) Equal(y Decimal) bool {
+	x, y = x.Normalize(), y.Normalize()
+	switch {
+	case x.scale != y.scale:
+		return false
+	case x.big == nil && y.big == nil:
+		return x.coef == y.coef
+	case x.big != nil && y.big != nil:
+		return x.big.Cmp(y.big) == 0
+	}
+	return false
+}
+
+//  Rat returns decimal as an exact fraction
+func (x Decimal) Rat() *big.Rat {
+	s := int64(x.scale)
+	if s < 0 {
+		s = -s
+	}
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(s), nil)
+	if x.scale < 0 {
+		return new(big.Rat).SetInt(p.Mul(p, x.Coef()))
+	}
+	return new(big.Rat).SetFrac(x.Coef(), p)
+}
+
+//  String returns decimal in plain notation keeping its scale, e.g. "1.50",
+//  or in exponent notation if that would take more than 32 padding zeros
+func (x Decimal) String() string {
+	digits, sign := x.Coef().String(), ""
+	if digits[0] == '-' {
+		digits, sign = digits[1:], "-"
+	}
+	scale := int64(x.scale)
+	switch {
+	case scale <= 0 && digits == "0":
+		return "0"
+	case scale <= 0 && -scale <= decimalMaxZeros:
+		return sign + digits + strings.Repeat("0", int(-scale))
+	case scale > 0 && scale < int64(len(digits)):
+		return sign + digits[:len(digits)-int(scale)] + "." + digits[len(digits)-int(scale):]
+	case scale > 0 && scale-int64(len(digits)) <= decimalMaxZeros:
+		return sign + "0." + strings.Repeat("0", int(scale)-len(digits)) + digits
+	}
+	return sign + digits + "e" + strconv.FormatInt(-scale, 10)
+}
+
+//  Decimal encodes normalized decimal into buffer as Int64 of scale shifted left by 1 bit,
+//  with low bit set for coefficient encoded by BigInt and clear for one encoded by Int64
+func (e *Enc) Decimal(x Decimal) {
+	if e.err != nil {
+		return
+	}
+	x = x.Normalize()
+	if x.big == nil {
+		e.Int64(int64(x.scale) << 1)
+		e.Int64(x.coef)
+		return
+	}
+	e.Int64(int64(x.scale)<<1 | 1)
+	e.BigInt(x.big)
+}
+
+//  Decimal decodes a decimal from buffer, non-normalized decimal is a decoding error
+//  scale counts to limit of big numbers (see SetBigLimit) as powers of ten do
+func (d *Dec) Decimal() Decimal {
+	s := d.Int64()
+	if d.err != nil {
+		return Decimal{}
+	}
+	scale := s >> 1
+	if scale < math.MinInt32 || scale > math.MaxInt32 {
+		d.err = errDecode
+		return Decimal{}
+	}
+	a := scale
+	if a < 0 {
+		a = -a
+	}
+	if !d.bigLimit(int(a / 2)) {
+		return Decimal{}
+	}
+	var x Decimal
+	if s&1 == 0 {
+		x = Decimal{coef: d.Int64(), scale: int32(scale)}
+	} else if c := d.BigInt(); c != nil {
+		if c.IsInt64() {
+			d.err = errDecode
+		}
+		x = Decimal{big: c, scale: int32(scale)}
+	}
+	if d.err != nil {
+		return Decimal{}
+	}
+	if n := x.Normalize(); n.scale != x.scale {
+		d.err = errDecode
+		return Decimal{}
+	}
+	return x
+}
@@ -0,0 +1,94 @@
+package encdec
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		coef  string
+		scale int32
+		out   string
+	}{
+		{"0", "0", 0, "0"},
+		{"-12.50", "-1250", 2, "-12.50"},
+		{"+.5", "5", 1, "0.5"},
+		{"1.25e-3", "125", 5, "0.00125"},
+		{"7E2", "7", -2, "700"},
+		{"1e40", "1", -40, "1e40"},
+		{"-3e-50", "-3", 50, "-3e-50"},
+		{"123456789012345678901234567890.1", "1234567890123456789012345678901", 1, "123456789012345678901234567890.1"},
+	} {
+		x, err := ParseDecimal(tc.in)
+		if err != nil || x.Coef().String() != tc.coef || x.Scale() != tc.scale || x.String() != tc.out {
+			t.Errorf("%q: expected: %v %v %v and got: %v %v %v, %v", tc.in, tc.coef, tc.scale, tc.out, x.Coef(), x.Scale(), x, err)
+		}
+	}
+	for _, s := range []string{"", "-", ".", "1.2.3", "1e", "1e99999999999", "0x10", "1_000", "1 "} {
+		if _, err := ParseDecimal(s); err != errDecimal {
+			t.Errorf("%q: expected: %v and got: %v", s, errDecimal, err)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-98765432109876543210000", 10)
+	x, y := NewDecimal(150, 2), NewDecimal(15, 1)
+	if !x.Equal(y) || x.String() != "1.50" || !NewDecimalBig(huge, 3).Equal(NewDecimalBig(new(big.Int).Quo(huge, big.NewInt(10000)), -1)) || x.Equal(NewDecimal(15, 2)) {
+		t.Error("unexpected equality")
+	}
+	if r := NewDecimal(-5, 1).Rat(); r.Cmp(big.NewRat(-1, 2)) != 0 {
+		t.Errorf("expected: %v and got: %v", big.NewRat(-1, 2), r)
+	}
+	if r := NewDecimal(2, -2).Rat(); r.Cmp(big.NewRat(200, 1)) != 0 {
+		t.Errorf("expected: %v and got: %v", big.NewRat(200, 1), r)
+	}
+
+	//equal amounts encode identically
+	for _, tagged := range []bool{false, true} {
+		enc1, enc2 := NewEnc(), NewEnc()
+		enc1.SetTagged(tagged)
+		enc2.SetTagged(tagged)
+		decs := []Decimal{x, NewDecimal(0, 5), NewDecimalBig(huge, 3), NewDecimal(-7, -3)}
+		for _, d := range decs {
+			enc1.Decimal(d)
+			enc2.Decimal(d.Normalize())
+		}
+		enc2.Decimal(y)
+		enc1.Decimal(x)
+		if string(enc1.Bytes()) != string(enc2.Bytes()) {
+			t.Errorf("expected: %v and got: %v", enc1.Bytes(), enc2.Bytes())
+		}
+		dec := NewDec(enc1.Bytes())
+		dec.SetTagged(tagged)
+		for _, d := range decs {
+			if z := dec.Decimal(); !z.Equal(d) || z.Scale() != d.Normalize().Scale() {
+				t.Errorf("expected: %v and got: %v, %v", d, z, dec.Error())
+			}
+		}
+	}
+
+	//non-normalized data
+	enc := NewEnc()
+	enc.Int64(2 << 1)
+	enc.Int64(150)
+	enc.Int64(1)
+	enc.BigInt(big.NewInt(3))
+	dec := NewDec(enc.Bytes())
+	if dec.Decimal(); dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+	dec = NewDec(enc.Bytes()[enc.Len()-6:])
+	if dec.Decimal(); dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+	enc.Reset()
+	enc.Int64(1 << 20)
+	enc.Int64(1)
+	dec = NewDec(enc.Bytes())
+	if dec.Decimal(); dec.Error() != errBigLimit {
+		t.Errorf("expected: %v and got: %v", errBigLimit, dec.Error())
+	}
+}
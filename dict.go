@@ -0,0 +1,202 @@
+package encdec
+
+//  In dictionary mode every ByteSlice and Str is preceded by a Uint64 marker:
+//  dictLiteral and dictAdd are followed by the literal, the latter adds it to the actual table,
+//  a marker from dictRef up refers to entry marker-dictRef of the table (pre-shared entries go first).
+//  Every record field has a table of its own starting empty and dropped at its end, so a field can be
+//  skipped or kept as RawField without the rest of the stream losing track of the table.
+const (
+	dictLiteral = 0
+	dictAdd     = 1
+	dictRef     = 2
+
+	//  dictMaxLen is the longest interned literal, longer ones are rarely repeated
+	dictMaxLen = 1024
+	//  dictMaxSize limits number of entries added to table by encoder
+	dictMaxSize = 1 << 16
+)
+
+//  Dict is a pre-shared dictionary of strings seeding tables of encoders and decoders in dictionary mode
+//  it pays off for short messages repeating well known strings, e.g. keys and host names of log records
+type Dict struct {
+	entries []*dictEntry
+	index   map[string]uint64
+}
+
+type dictEntry struct {
+	s string
+	b []byte
+}
+
+func (de *dictEntry) str() string {
+	if de.s == "" && len(de.b) > 0 {
+		de.s = string(de.b)
+	}
+	return de.s
+}
+
+//  NewDict returns a dictionary of entries, encoder and decoder have to use the same one
+func NewDict(entries ...string) *Dict {
+	d := &Dict{index: make(map[string]uint64, len(entries))}
+	for _, s := range entries {
+		if _, ok := d.index[s]; !ok {
+			d.index[s] = uint64(len(d.entries))
+			d.entries = append(d.entries, &dictEntry{s: s, b: []byte(s)})
+		}
+	}
+	return d
+}
+
+//  encTable is a table of strings added to encoded stream or record field
+type encTable struct {
+	ids  map[string]uint64
+	keys []string // in order of ids
+	raw  bool     // field holds RawField data with entries encoder does not know
+}
+
+//  encDict is a table of strings of encoded stream
+type encDict struct {
+	seed *Dict
+	encTable
+}
+
+//  SetDict switches encoder to dictionary mode, repeated byte slices and strings are encoded as references
+//  into a table of the stream or record field seeded by d, nil switches dictionary mode off
+//  decoder has to use the same mode and dictionary, the table starts empty after Reset
+func (e *Enc) SetDict(d *Dict) {
+	e.dict = nil
+	if d != nil {
+		e.dict = &encDict{seed: d}
+	}
+}
+
+//  dictTable returns table of actual record field or of the stream
+func (e *Enc) dictTable() *encTable {
+	if n := len(e.fields); n > 0 {
+		return &e.fields[n-1].dict
+	}
+	return &e.dict.encTable
+}
+
+//  intern encodes dictionary marker of x, true means x was encoded as a reference
+func (e *Enc) intern(x []byte) bool {
+	t := e.dictTable()
+	id, ok := e.dict.seed.index[string(x)]
+	if !ok {
+		id, ok = t.ids[string(x)]
+	}
+	if ok {
+		e.Uint64(dictRef + id)
+		return true
+	}
+	if len(x) > dictMaxLen || len(t.keys) >= dictMaxSize || t.raw {
+		e.Uint64(dictLiteral)
+		return false
+	}
+	if t.ids == nil {
+		t.ids = make(map[string]uint64)
+	}
+	s := string(x)
+	t.ids[s] = uint64(len(e.dict.seed.entries) + len(t.keys))
+	t.keys = append(t.keys, s)
+	e.Uint64(dictAdd)
+	return false
+}
+
+//  dictLen returns number of entries added to actual encoding table
+func (e *Enc) dictLen() int {
+	if e.dict == nil {
+		return 0
+	}
+	return len(e.dictTable().keys)
+}
+
+//  rollbackDict removes entries added after actual table had n of them
+func (e *Enc) rollbackDict(n int) {
+	if e.dict == nil {
+		return
+	}
+	t := e.dictTable()
+	if n > len(t.keys) {
+		return
+	}
+	for _, s := range t.keys[n:] {
+		delete(t.ids, s)
+	}
+	t.keys = t.keys[:n]
+}
+
+//  decDict is a table of strings of decoded stream
+type decDict struct {
+	seed    *Dict
+	entries []*dictEntry
+}
+
+//  SetDict switches decoder to dictionary mode of encoder (see Enc.SetDict), nil switches it off
+//  byte slices decoded from table entries are shared by all their occurrences and must not be modified
+//  as skipped data can add to the table, Skip and Seek are not available in dictionary mode
+func (d *Dec) SetDict(dict *Dict) {
+	d.dict = nil
+	if dict != nil {
+		d.dict = &decDict{seed: dict}
+	}
+}
+
+//  interned decodes dictionary marker and literal of kind k following it
+//  it returns table entry for references and added literals, or the literal itself
+func (d *Dec) interned(k Kind) (*dictEntry, []byte) {
+	m := d.Uint64()
+	if d.err != nil {
+		return nil, nil
+	}
+	seed, t := d.dict.seed, d.dictTable()
+	if m >= dictRef {
+		i := m - dictRef
+		if i < uint64(len(seed.entries)) {
+			return seed.entries[i], nil
+		}
+		if i -= uint64(len(seed.entries)); i < uint64(len(*t)) {
+			return (*t)[i], nil
+		}
+		d.err = errDecode
+		return nil, nil
+	}
+	if !d.tag(k) {
+		return nil, nil
+	}
+	b := d.byteSlice()
+	if d.err != nil || m == dictLiteral {
+		return nil, b
+	}
+	de := &dictEntry{b: append([]byte{}, b...)}
+	*t = append(*t, de)
+	return de, nil
+}
+
+//  dictTable returns table of actual record field or of the stream
+func (d *Dec) dictTable() *[]*dictEntry {
+	for i := len(d.fields) - 1; i >= 0; i-- {
+		if d.fields[i].end >= 0 {
+			return &d.fields[i].dict
+		}
+	}
+	return &d.dict.entries
+}
+
+//  dictBytes decodes byte slice of kind k in dictionary mode, table entries are returned shared
+func (d *Dec) dictBytes(k Kind) []byte {
+	de, b := d.interned(k)
+	if de != nil {
+		return de.b
+	}
+	return b
+}
+
+//  dictLen returns number of entries added to decoding table of the stream
+//  tables of record fields are kept by decField
+func (d *Dec) dictLen() int {
+	if d.dict == nil {
+		return 0
+	}
+	return len(d.dict.entries)
+}
@@ -0,0 +1,183 @@
+package encdec
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestDict(t *testing.T) {
+	hosts := []string{"web-1.example.com", "web-2.example.com", "db.example.com"}
+	long := strings.Repeat("x", dictMaxLen+1)
+	encode := func(enc *Enc) {
+		for i := 0; i < 30; i++ {
+			enc.Str("host")
+			enc.Str(hosts[i%3])
+			enc.ByteSlice([]byte("level"))
+			enc.Uint64(uint64(i))
+		}
+		enc.Str(long)
+		enc.Str(long)
+		enc.ByteSlice([]byte{})
+	}
+	plain := NewEnc()
+	encode(plain)
+	//long strings are never referenced
+	limit := (plain.Len()-2*len(long))/2 + 2*len(long)
+	for _, dict := range []*Dict{NewDict(), NewDict("host", "level", "host")} {
+		for _, tagged := range []bool{false, true} {
+			enc := NewEnc()
+			enc.SetTagged(tagged)
+			enc.SetDict(dict)
+			encode(enc)
+			if enc.Error() != nil || enc.Len() >= limit {
+				t.Fatalf("expected: less than %v and got: %v, %v", limit, enc.Len(), enc.Error())
+			}
+			dec := NewDec(enc.Bytes())
+			dec.SetTagged(tagged)
+			dec.SetDict(dict)
+			var first string
+			for i := 0; i < 30; i++ {
+				if s := dec.Str(); s != "host" {
+					t.Fatalf("expected: host and got: %v", s)
+				}
+				h := dec.Str()
+				if h != hosts[i%3] || !bytes.Equal(dec.ByteSliceCopy(), []byte("level")) || dec.Uint64() != uint64(i) {
+					t.Fatalf("expected: %v and got: %v, %v", hosts[i%3], h, dec.Error())
+				}
+				//repeated strings are interned
+				if i == 0 {
+					first = h
+				} else if i%3 == 0 && unsafe.StringData(h) != unsafe.StringData(first) {
+					t.Errorf("string %v is not interned", h)
+				}
+			}
+			if dec.Str() != long || dec.Str() != long || len(dec.ByteSlice()) != 0 || dec.Error() != nil || dec.Len() != 0 {
+				t.Errorf("expected: nil and got: %v", dec.Error())
+			}
+		}
+	}
+
+	//rolled back entries are added again
+	enc := NewEnc()
+	enc.SetDict(NewDict())
+	cp := enc.Checkpoint()
+	enc.Str("a")
+	enc.Rollback(cp)
+	enc.Str("a")
+	enc.Str("a")
+	dec := NewDec(enc.Bytes())
+	dec.SetDict(NewDict())
+	m := dec.Mark()
+	dec.Str()
+	dec.Rewind(m)
+	if dec.Str() != "a" || dec.Str() != "a" || dec.Error() != nil {
+		t.Errorf("expected: nil and got: %v", dec.Error())
+	}
+
+	//table starts empty after reset
+	enc.Reset()
+	enc.Str("a")
+	if !bytes.Equal(enc.Bytes(), []byte{1, dictAdd, 1, 1, 'a'}) {
+		t.Errorf("expected: %v and got: %v", []byte{1, dictAdd, 1, 1, 'a'}, enc.Bytes())
+	}
+
+	//references out of table
+	enc = NewEnc()
+	enc.SetDict(NewDict("a"))
+	enc.Str("a")
+	dec = NewDec(enc.Bytes())
+	dec.SetDict(NewDict())
+	if dec.Str(); dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+
+	//skipped fields do not shift references
+	for _, tagged := range []bool{false, true} {
+		enc := NewEnc()
+		enc.SetTagged(tagged)
+		enc.SetDict(NewDict())
+		enc.Str("public")
+		enc.BeginRecord()
+		enc.Field(1)
+		enc.Str("secret")
+		enc.Str("secret")
+		enc.Field(2)
+		enc.Str("public")
+		enc.Str("secret")
+		enc.EndRecord()
+		enc.Str("secret")
+		enc.Str("public")
+		data := enc.Bytes()
+
+		dec := NewDec(data)
+		dec.SetTagged(tagged)
+		dec.SetDict(NewDict())
+		dec.Str()
+		var got []string
+		var raw []RawField
+		dec.BeginRecord()
+		for id, ok := dec.NextField(); ok; id, ok = dec.NextField() {
+			if id == 2 {
+				got = append(got, dec.Str(), dec.Str())
+			} else {
+				raw = append(raw, dec.RawField())
+			}
+		}
+		got = append(got, dec.Str(), dec.Str())
+		if dec.Error() != nil || strings.Join(got, ",") != "public,secret,secret,public" {
+			t.Errorf("tagged %v: expected: %v and got: %v, %v", tagged, "public,secret,secret,public", got, dec.Error())
+		}
+
+		//unknown field re-encoded verbatim
+		re := NewEnc()
+		re.SetTagged(tagged)
+		re.SetDict(NewDict())
+		re.Str("public")
+		re.BeginRecord()
+		re.RawField(raw[0])
+		re.Str("x")
+		re.Field(2)
+		re.Str("public")
+		re.Str("secret")
+		re.EndRecord()
+		dec = NewDec(re.Bytes())
+		dec.SetTagged(tagged)
+		dec.SetDict(NewDict())
+		dec.Str()
+		dec.BeginRecord()
+		dec.NextField()
+		if a, b, c := dec.Str(), dec.Str(), dec.Str(); a != "secret" || b != "secret" || c != "x" || dec.Error() != nil {
+			t.Errorf("tagged %v: expected: secret secret x and got: %v %v %v, %v", tagged, a, b, c, dec.Error())
+		}
+
+		//raw part of a field that added to the table
+		dec = NewDec(data)
+		dec.SetTagged(tagged)
+		dec.SetDict(NewDict())
+		dec.Str()
+		dec.BeginRecord()
+		dec.NextField()
+		dec.Str()
+		if dec.RawField(); dec.Error() != errDecode {
+			t.Errorf("tagged %v: expected: %v and got: %v", tagged, errDecode, dec.Error())
+		}
+	}
+
+	//skipping and seeking are not available
+	enc = NewEnc()
+	enc.SetTagged(true)
+	enc.SetDict(NewDict())
+	enc.Str("a")
+	dec = NewDec(enc.Bytes())
+	dec.SetTagged(true)
+	dec.SetDict(NewDict())
+	if _, err := dec.Seek(0, io.SeekEnd); err != errSeek {
+		t.Errorf("expected: %v and got: %v", errSeek, err)
+	}
+	if dec.Skip(); dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"errors"
 	"io"
 	"math"
+	"os"
+	"time"
 )
 
 var (
@@ -16,6 +18,7 @@ var (
 	errDecode               = errors.New("encdec: decoding error")
 	errNoDecData            = errors.New("encdec: nothing to decode")
 	errDecodeNotEnoughtData = errors.New("encdec: not enought data to decode")
+	errDecodeTooMuchData    = errors.New("encdec: read limit exceeded")
 )
 
 //  Enc is a simple encoder
@@ -25,6 +28,21 @@ type Enc struct {
 	buf64  [binary.MaxVarintLen64]byte
 	encbuf []byte
 	lng    int
+	vecmin int
+	tagged bool
+	refs   []encRef
+	reflen int
+	gen    int
+
+	fields  []encField
+	records []encRecord
+	nrec    int
+
+	shared    []interface{}
+	sharedIDs map[interface{}]uint64
+	dict      *encDict
+	tprec     time.Duration
+	tzone     TimeZone
 }
 
 func NewEnc() *Enc {
@@ -39,6 +57,9 @@ func (e *Enc) WriteTo(w io.Writer) (int64, error) {
 	if e.err != nil {
 		return 0, e.err
 	}
+	if len(e.refs) > 0 {
+		return e.writeBuffers(w)
+	}
 	e.lng, e.err = w.Write(e.encbuf)
 	if e.lng < len(e.encbuf) {
 		e.err = errEncode
@@ -50,6 +71,16 @@ func (e *Enc) WriteTo(w io.Writer) (int64, error) {
 func (e *Enc) Reset() {
 	e.err = nil
 	e.encbuf = e.encbuf[0:0]
+	e.refs = nil
+	e.reflen = 0
+	e.gen++
+	e.fields = nil
+	e.records = nil
+	e.shared = nil
+	e.sharedIDs = nil
+	if e.dict != nil {
+		e.SetDict(e.dict.seed)
+	}
 }
 
 //  Marshaler encodes a encoding.BinaryMarshaler into buffer
@@ -66,7 +97,12 @@ func (e *Enc) Marshaler(x encoding.BinaryMarshaler) {
 	if e.err != nil {
 		return
 	}
-	e.ByteSlice(buf)
+	if buf == nil {
+		e.err = errEncode
+		return
+	}
+	e.tag(KindNested)
+	e.bytes(buf)
 }
 
 //  Float64 encodes a float64 into buffer
@@ -74,7 +110,8 @@ func (e *Enc) Float64(x float64) {
 	if e.err != nil {
 		return
 	}
-	e.Uint64(math.Float64bits(x))
+	e.tag(KindFloat)
+	e.uvarint(math.Float64bits(x))
 }
 
 //  Int64 encodes a int64 into buffer
@@ -82,6 +119,12 @@ func (e *Enc) Int64(x int64) []byte {
 	if e.err != nil {
 		return nil
 	}
+	e.tag(KindInt)
+	return e.varint(x)
+}
+
+//  varint encodes a int64 into buffer without kind tag
+func (e *Enc) varint(x int64) []byte {
 	// defer func(e *Enc) {
 	// 	if r := recover(); r != nil {
 	// 		e.err = errEncode
@@ -103,6 +146,12 @@ func (e *Enc) Uint64(x uint64) []byte {
 	if e.err != nil {
 		return nil
 	}
+	e.tag(KindUint)
+	return e.uvarint(x)
+}
+
+//  uvarint encodes a uint64 into buffer without kind tag
+func (e *Enc) uvarint(x uint64) []byte {
 	// defer func(e *Enc) {
 	// 	if r := recover(); r != nil {
 	// 		e.err = errEncode
@@ -128,6 +177,29 @@ func (e *Enc) ByteSlice(x []byte) {
 		e.err = errEncode
 		return
 	}
+	if e.dict != nil && e.intern(x) {
+		return
+	}
+	e.tag(KindBytes)
+	e.bytes(x)
+}
+
+//  Str encodes a string into buffer, untagged encoding is the same as of ByteSlice
+func (e *Enc) Str(x string) {
+	if e.err != nil {
+		return
+	}
+	if e.dict != nil && e.intern([]byte(x)) {
+		return
+	}
+	e.tag(KindString)
+	e.lng = len(x)
+	e.uvarint(uint64(e.lng))
+	e.encbuf = append(e.encbuf, x...)
+}
+
+//  bytes encodes a slice of bytes into buffer without kind tag
+func (e *Enc) bytes(x []byte) {
 	e.lng = len(x)
 	// if e.lng > 0 && e.lng < 256 {
 	// 	e.encbuf = append(e.encbuf, byte(e.lng))
@@ -135,12 +207,29 @@ func (e *Enc) ByteSlice(x []byte) {
 	// 	e.encbuf = append(e.encbuf, byte(0))
 	// 	e.Uint64(uint64(e.lng))
 	// }
-	e.Uint64(uint64(e.lng))
+	if e.vecmin > 0 && e.lng >= e.vecmin {
+		e.reflen += e.lng
+		e.uvarint(uint64(e.lng))
+		e.refs = append(e.refs, encRef{off: len(e.encbuf), buf: x})
+		return
+	}
+	e.uvarint(uint64(e.lng))
 	if e.lng > 0 {
 		e.encbuf = append(e.encbuf, x...)
 	}
 }
 
+//  endMarker is a header byte no encoded entity starts with
+const endMarker = 0
+
+//  end encodes an end marker into buffer
+func (e *Enc) end() {
+	if e.err != nil {
+		return
+	}
+	e.encbuf = append(e.encbuf, endMarker)
+}
+
 // Byte encodes a byte into buffer
 // func (e *Enc) Byte(x byte) {
 // 	if e.err != nil {
@@ -154,11 +243,41 @@ func (e Enc) Bytes() []byte {
 	if e.err != nil {
 		return nil
 	}
+	if len(e.refs) > 0 {
+		return e.appendFlat(make([]byte, 0, e.Len()))
+	}
 	ret := make([]byte, len(e.encbuf))
 	copy(ret, e.encbuf)
 	return ret
 }
 
+//  AppendTo appends encoded data to dst and returns the extended slice
+//  the result never aliases internal buffer of encoder
+func (e *Enc) AppendTo(dst []byte) []byte {
+	if e.err != nil {
+		return dst
+	}
+	return e.appendFlat(dst)
+}
+
+//  Detach hands over internal buffer with encoded data to the caller without copying
+//  encoder is reset and starts with a fresh buffer, so it never writes into returned slice again
+func (e *Enc) Detach() []byte {
+	if e.err != nil {
+		return nil
+	}
+	if len(e.refs) > 0 {
+		// referenced payloads have to be gathered anyway
+		buf := e.Bytes()
+		e.Reset()
+		return buf
+	}
+	buf := e.encbuf
+	e.encbuf = make([]byte, 0, 1024)
+	e.Reset()
+	return buf
+}
+
 //  Error returns encoding error if any
 func (e Enc) Error() error {
 	return e.err
@@ -166,7 +285,7 @@ func (e Enc) Error() error {
 
 //  Len returns actual length of encoded data
 func (e Enc) Len() int {
-	return len(e.encbuf)
+	return len(e.encbuf) + e.reflen
 }
 
 //  Dec is a simple decoder
@@ -177,8 +296,33 @@ type Dec struct {
 	lng    int
 	lst    int
 	decbuf []byte
+	mode   CopyMode
+	arena  []byte
+	limit  int64
+	tagged bool
+	fields []decField
+	chunks [][]byte
+	orig   [][]byte
+	skip   int
+	base   int
+	rest   int
+	shared []interface{}
+	dict   *decDict
+	bigmax int
 }
 
+//  CopyMode controls whether byte slices returned by decoder alias its input buffer
+type CopyMode int
+
+const (
+	//  CopyNone returns subslices of input buffer (default)
+	CopyNone CopyMode = iota
+	//  CopyAlways returns a freshly allocated copy of every decoded byte slice
+	CopyAlways
+	//  CopyArena copies decoded byte slices into one shared block to keep allocation count low
+	CopyArena
+)
+
 func NewDec(b []byte) (d *Dec) {
 	d = &Dec{
 		err:    nil,
@@ -192,35 +336,134 @@ func NewDec(b []byte) (d *Dec) {
 	return
 }
 
-//  ReadFrom reads data from a io.Reader
+//  ReadFrom reads data from a io.Reader until EOF and appends it to undecoded buffer
+//  buffer is presized when reader reports its size (Len or Stat) and grows geometrically otherwise
 func (d *Dec) ReadFrom(r io.Reader) (int64, error) {
 	if d.err != nil {
 		return 0, d.err
 	}
-	buf := make([]byte, 256)
-	n := 0
-	tn := 0
+	return d.readFrom(r, readSizeHint(r))
+}
+
+//  readFrom reads data from a io.Reader expected to deliver hint bytes
+func (d *Dec) readFrom(r io.Reader, hint int64) (int64, error) {
+	if d.orig != nil {
+		return d.readChunk(r, hint)
+	}
+	if hint > 0 {
+		if d.limit > 0 && hint > d.limit {
+			hint = d.limit
+		}
+		// one spare byte so the final EOF read does not force another growth
+		d.grow(int(hint) + 1)
+	}
+	var (
+		n  int64
+		tn int
+	)
 	for {
-		tn, d.err = r.Read(buf)
-		n = n + tn
-		if d.err != nil && d.err != io.EOF {
-			return int64(n), d.err
+		if len(d.decbuf) == cap(d.decbuf) {
+			d.grow(minRead)
+		}
+		free := d.decbuf[len(d.decbuf):cap(d.decbuf)]
+		if d.limit > 0 && int64(len(free)) > d.limit-n+1 {
+			// one byte over the limit is enough to detect oversized input
+			free = free[:d.limit-n+1]
+		}
+		tn, d.err = r.Read(free)
+		if tn < 0 {
+			tn = 0
 		}
-		if tn > 0 {
-			d.decbuf = append(d.decbuf, buf[:tn]...)
+		d.decbuf = d.decbuf[:len(d.decbuf)+tn]
+		n = n + int64(tn)
+		if d.limit > 0 && n > d.limit {
+			d.decbuf = d.decbuf[:len(d.decbuf)-int(n-d.limit)]
+			d.err = errDecodeTooMuchData
+			return d.limit, d.err
 		}
-		if d.err != nil && d.err == io.EOF {
+		if d.err == io.EOF {
 			d.err = nil
-			return int64(n), nil
+			return n, nil
+		}
+		if d.err != nil {
+			return n, d.err
 		}
 	}
+}
 
+//  SetReadLimit sets maximum number of bytes a single ReadFrom call accepts, 0 means no limit
+func (d *Dec) SetReadLimit(n int64) {
+	if n < 0 {
+		n = 0
+	}
+	d.limit = n
+}
+
+//  minRead is the minimal free space offered to a single Read call
+const minRead = 512
+
+//  grow ensures at least n bytes of free capacity in decoding buffer
+func (d *Dec) grow(n int) {
+	if cap(d.decbuf)-len(d.decbuf) >= n {
+		return
+	}
+	c := 2 * cap(d.decbuf)
+	if c < len(d.decbuf)+n {
+		c = len(d.decbuf) + n
+	}
+	buf := make([]byte, len(d.decbuf), c)
+	copy(buf, d.decbuf)
+	d.decbuf = buf
+}
+
+//  readSizeHint returns number of bytes r is expected to deliver or 0 if unknown
+func readSizeHint(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case interface{ Stat() (os.FileInfo, error) }:
+		fi, err := v.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return 0
+		}
+		size := fi.Size()
+		if sk, ok := r.(io.Seeker); ok {
+			if off, err := sk.Seek(0, io.SeekCurrent); err == nil {
+				size -= off
+			}
+		}
+		if size < 0 {
+			return 0
+		}
+		return size
+	}
+	return 0
 }
 
 //  Reset resets decoder to initial state
 func (d *Dec) Reset() {
 	d.err = nil
 	d.i = 0
+	d.fields = nil
+	d.shared = nil
+	if d.dict != nil {
+		d.SetDict(d.dict.seed)
+	}
+	if d.orig != nil {
+		d.resetChunks()
+	}
+}
+
+//  SetCopyMode sets aliasing policy for byte slices returned by ByteSlice and passed to Unmarshaler
+//  with CopyAlways or CopyArena returned slices stay valid after input buffer is reused
+func (d *Dec) SetCopyMode(m CopyMode) {
+	d.mode = m
+	d.arena = nil
+}
+
+//  CopyMode returns actual aliasing policy of decoder
+func (d Dec) CopyMode() CopyMode {
+	return d.mode
 }
 
 //  Unmarshaler decodes a encoding.BinaryUnmarshaler from buffer
@@ -228,6 +471,7 @@ func (d *Dec) Unmarshaler(x encoding.BinaryUnmarshaler) {
 	if d.err != nil {
 		return
 	}
+	d.need(1)
 	if d.i >= len(d.decbuf) || d.i < 0 /*overflow*/ {
 		d.err = errNoDecData
 		return
@@ -236,8 +480,10 @@ func (d *Dec) Unmarshaler(x encoding.BinaryUnmarshaler) {
 		d.err = errDecode
 		return
 	}
-
-	d.err = x.UnmarshalBinary(d.ByteSlice())
+	if !d.tag(KindNested) {
+		return
+	}
+	d.err = x.UnmarshalBinary(d.copied(d.byteSlice()))
 }
 
 //  Float64 decodes a float64 from buffer
@@ -245,18 +491,31 @@ func (d *Dec) Float64() float64 {
 	if d.err != nil {
 		return 0.0
 	}
+	d.need(1)
 	if d.i >= len(d.decbuf) || d.i < 0 /*overflow*/ {
 		d.err = errNoDecData
 		return 0.0
 	}
-	return math.Float64frombits(d.Uint64())
+	if !d.tag(KindFloat) {
+		return 0.0
+	}
+	return math.Float64frombits(d.uvarint())
 }
 
 //  Int64 decodes a int64 from buffer
 func (d *Dec) Int64() int64 {
+	if !d.tag(KindInt) {
+		return 0
+	}
+	return d.varint()
+}
+
+//  varint decodes a int64 from buffer without kind tag
+func (d *Dec) varint() int64 {
 	if d.err != nil {
 		return 0
 	}
+	d.need(1)
 	if d.i >= len(d.decbuf) || d.i < 0 /*overflow*/ {
 		d.err = errNoDecData
 		return 0
@@ -267,6 +526,7 @@ func (d *Dec) Int64() int64 {
 	// 	return 0
 	// }
 	d.i++
+	d.need(d.lng)
 	d.lst = d.i + d.lng
 	if d.lst > len(d.decbuf) {
 		d.err = errDecodeNotEnoughtData
@@ -288,9 +548,18 @@ func (d *Dec) Int64() int64 {
 
 //  Uint64 decodes a uint64 from buffer
 func (d *Dec) Uint64() uint64 {
+	if !d.tag(KindUint) {
+		return 0
+	}
+	return d.uvarint()
+}
+
+//  uvarint decodes a uint64 from buffer without kind tag
+func (d *Dec) uvarint() uint64 {
 	if d.err != nil {
 		return 0
 	}
+	d.need(1)
 	if d.i >= len(d.decbuf) || d.i < 0 /*overflow*/ {
 		d.err = errNoDecData
 		return 0
@@ -301,6 +570,7 @@ func (d *Dec) Uint64() uint64 {
 	// 	return 0
 	// }
 	d.i++
+	d.need(d.lng)
 	d.lst = d.i + d.lng
 	if d.lst > len(d.decbuf) {
 		d.err = errDecodeNotEnoughtData
@@ -322,10 +592,82 @@ func (d *Dec) Uint64() uint64 {
 }
 
 //  ByteSlice decodes a slice of bytes from buffer
+//  returned slice aliases input buffer unless copy mode is set (see SetCopyMode)
 func (d *Dec) ByteSlice() []byte {
+	if d.dict != nil {
+		return d.copied(d.dictBytes(KindBytes))
+	}
+	if !d.tag(KindBytes) {
+		return nil
+	}
+	return d.copied(d.byteSlice())
+}
+
+//  Str decodes a string from buffer
+func (d *Dec) Str() string {
+	if d.dict != nil {
+		de, b := d.interned(KindString)
+		if de != nil {
+			return de.str()
+		}
+		return string(b)
+	}
+	if !d.tag(KindString) {
+		return ""
+	}
+	return string(d.byteSlice())
+}
+
+//  ByteSliceCopy decodes a slice of bytes from buffer
+//  returned slice never aliases input buffer regardless of copy mode
+func (d *Dec) ByteSliceCopy() []byte {
+	var buf []byte
+	if d.dict != nil {
+		buf = d.dictBytes(KindBytes)
+	} else if d.tag(KindBytes) {
+		buf = d.byteSlice()
+	}
+	if len(buf) == 0 {
+		return buf
+	}
+	if d.mode == CopyArena {
+		return d.copyBytes(buf)
+	}
+	ret := make([]byte, len(buf))
+	copy(ret, buf)
+	return ret
+}
+
+//  copied returns b copied out of input buffer if copy mode requires it
+func (d *Dec) copied(b []byte) []byte {
+	if len(b) == 0 || d.mode == CopyNone {
+		return b
+	}
+	return d.copyBytes(b)
+}
+
+//  copyBytes copies b out of input buffer according to copy mode
+func (d *Dec) copyBytes(b []byte) []byte {
+	if d.mode != CopyArena {
+		ret := make([]byte, len(b))
+		copy(ret, b)
+		return ret
+	}
+	if cap(d.arena)-len(d.arena) < len(b) {
+		// remaining undecoded data is an upper bound of what will be copied later
+		d.arena = make([]byte, 0, len(b)+d.Len())
+	}
+	n := len(d.arena)
+	d.arena = append(d.arena, b...)
+	return d.arena[n:len(d.arena):len(d.arena)]
+}
+
+//  byteSlice decodes a slice of bytes aliasing input buffer
+func (d *Dec) byteSlice() []byte {
 	if d.err != nil {
 		return nil
 	}
+	d.need(1)
 	if d.i >= len(d.decbuf) || d.i < 0 /*overflow*/ {
 		d.err = errNoDecData
 		return nil
@@ -341,7 +683,7 @@ func (d *Dec) ByteSlice() []byte {
 	// 		return nil
 	// 	}
 	// }
-	d.lng = int(d.Uint64())
+	d.lng = int(d.uvarint())
 	if d.lng < 0 {
 		d.err = errDecode
 		return nil
@@ -349,6 +691,7 @@ func (d *Dec) ByteSlice() []byte {
 	if d.lng == 0 {
 		return []byte{}
 	}
+	d.need(d.lng)
 	d.lst = d.i + d.lng
 	if d.lst < 0 {
 		d.err = errDecode
@@ -368,6 +711,19 @@ func (d *Dec) ByteSlice() []byte {
 	return buf
 }
 
+//  end decodes an end marker from buffer if it is next
+func (d *Dec) end() bool {
+	if d.err != nil {
+		return false
+	}
+	d.need(1)
+	if d.i >= len(d.decbuf) || d.i < 0 /*overflow*/ || d.decbuf[d.i] != endMarker {
+		return false
+	}
+	d.i++
+	return true
+}
+
 // Byte decodes a byte from buffer
 // func (d *Dec) Byte() byte {
 // 	if d.err != nil {
@@ -422,10 +778,10 @@ func (d Dec) Error() error {
 
 //  Len length of undecoded buffer
 func (d Dec) Len() int {
-	return len(d.decbuf) - d.Pos()
+	return len(d.decbuf) - d.i + d.rest
 }
 
 //  Pos returns actual decoding position in buffer
 func (d Dec) Pos() int {
-	return int(d.i)
+	return d.base + d.i
 }
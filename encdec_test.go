@@ -3,7 +3,11 @@ package encdec
 import (
 	"bytes"
 	"encoding/gob"
+	"io"
+	"math"
+	"os"
 	"testing"
+	"testing/iotest"
 	"testing/quick"
 	"time"
 )
@@ -272,6 +276,132 @@ func TestEncDecErrorCases(t *testing.T) {
 
 }
 
+func TestEncDetachAppendTo(t *testing.T) {
+	enc := NewEnc()
+	enc.Uint64(5)
+	enc.ByteSlice([]byte{1, 2, 3})
+	exp := enc.Bytes()
+
+	//AppendTo must not alias encoder buffer
+	dst := enc.AppendTo([]byte{9})
+	if !bytes.Equal(dst[1:], exp) || dst[0] != 9 {
+		t.Errorf("expected: %v and got: %v", exp, dst[1:])
+	}
+	dst[1] = 0xff
+	if !bytes.Equal(enc.Bytes(), exp) {
+		t.Error("AppendTo result aliases encoder buffer")
+	}
+
+	//Detach hands over buffer and resets encoder
+	buf := enc.Detach()
+	if !bytes.Equal(buf, exp) || enc.Len() != 0 || enc.Error() != nil {
+		t.Errorf("expected: %v and got: %v", exp, buf)
+	}
+	enc.Uint64(7)
+	enc.Uint64(8)
+	if !bytes.Equal(buf, exp) {
+		t.Error("encoder writes into detached buffer")
+	}
+	dec := NewDec(buf)
+	if dec.Uint64() != 5 || !bytes.Equal(dec.ByteSlice(), []byte{1, 2, 3}) || dec.Error() != nil {
+		t.Error("detached buffer decoding failed")
+	}
+
+	enc.err = errEncode
+	if enc.Detach() != nil || enc.Error() != errEncode {
+		t.Error("expected: nil and sticky error")
+	}
+	if dst := enc.AppendTo(nil); dst != nil {
+		t.Errorf("expected: nil and got: %v", dst)
+	}
+}
+
+func TestDecCopyMode(t *testing.T) {
+	enc := NewEnc()
+	enc.ByteSlice([]byte{1, 2, 3})
+	enc.ByteSlice([]byte{4, 5})
+	enc.Marshaler(time.Now())
+
+	for _, m := range []CopyMode{CopyNone, CopyAlways, CopyArena} {
+		buf := enc.Bytes()
+		dec := NewDec(buf)
+		dec.SetCopyMode(m)
+		a := dec.ByteSlice()
+		b := dec.ByteSliceCopy()
+		var ti time.Time
+		dec.Unmarshaler(&ti)
+		if dec.Error() != nil || dec.Len() != 0 {
+			t.Fatal(dec.Error())
+		}
+		for i := range buf {
+			buf[i] = 0
+		}
+		if !bytes.Equal(b, []byte{4, 5}) {
+			t.Errorf("mode %v: ByteSliceCopy aliases input buffer", m)
+		}
+		if aliased := !bytes.Equal(a, []byte{1, 2, 3}); aliased != (m == CopyNone) {
+			t.Errorf("mode %v: expected aliasing %v and got %v", m, m == CopyNone, aliased)
+		}
+		if m == CopyArena && cap(a) != len(a) {
+			t.Errorf("arena slice cap: expected %v and got %v", len(a), cap(a))
+		}
+	}
+}
+
+func TestDecReadFrom(t *testing.T) {
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	f, err := os.CreateTemp(t.TempDir(), "encdec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Write(data)
+	f.Seek(10, io.SeekStart)
+
+	readers := []io.Reader{
+		bytes.NewReader(data[10:]),
+		bytes.NewBuffer(data[10:]),
+		iotest.OneByteReader(bytes.NewReader(data[10:])),
+		iotest.DataErrReader(bytes.NewReader(data[10:])),
+		f,
+	}
+	for _, r := range readers {
+		dec := NewDec([]byte{1, 2, 3})
+		n, err := dec.ReadFrom(r)
+		if err != nil || n != int64(len(data)-10) {
+			t.Errorf("%T: expected: %v and got: %v, %v", r, len(data)-10, n, err)
+			continue
+		}
+		if !bytes.Equal(dec.decbuf[:3], []byte{1, 2, 3}) || !bytes.Equal(dec.decbuf[3:], data[10:]) {
+			t.Errorf("%T: read data differ", r)
+		}
+	}
+
+	//read limit
+	for _, r := range []io.Reader{bytes.NewReader(data), iotest.HalfReader(bytes.NewReader(data))} {
+		dec := NewDec([]byte{})
+		dec.SetReadLimit(1000)
+		n, err := dec.ReadFrom(r)
+		if err != errDecodeTooMuchData || n != 1000 || dec.Len() != 1000 || cap(dec.decbuf) > 2048 {
+			t.Errorf("%T: expected: %v and got: %v, %v (cap %v)", r, errDecodeTooMuchData, n, err, cap(dec.decbuf))
+		}
+	}
+	dec := NewDec([]byte{})
+	dec.SetReadLimit(int64(len(data)))
+	if n, err := dec.ReadFrom(bytes.NewReader(data)); err != nil || n != int64(len(data)) {
+		t.Errorf("expected: %v and got: %v, %v", len(data), n, err)
+	}
+
+	//read error is sticky
+	dec = NewDec([]byte{})
+	if _, err := dec.ReadFrom(iotest.ErrReader(errDecode)); err != errDecode || dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, err)
+	}
+}
+
 // benchmarks
 // simple values enc/dec
 func BenchmarkBasicEncodeGob(b *testing.B) {
@@ -442,6 +572,35 @@ func BenchmarkSliceDecodeEncDec(b *testing.B) {
 	}
 }
 
+// large input reading
+func benchmarkReadFrom(b *testing.B, size int, wrap func(f *os.File) io.Reader) {
+	f, err := os.CreateTemp(b.TempDir(), "encdec")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Write(make([]byte, size)); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Seek(0, io.SeekStart)
+		dec := NewDec([]byte{})
+		if _, err = dec.ReadFrom(wrap(f)); err != nil {
+			b.Error(err)
+			return
+		}
+	}
+}
+func BenchmarkReadFromFile(b *testing.B) {
+	benchmarkReadFrom(b, 16<<20, func(f *os.File) io.Reader { return f })
+}
+func BenchmarkReadFromReader(b *testing.B) {
+	// hides size of underlying file
+	benchmarkReadFrom(b, 16<<20, func(f *os.File) io.Reader { return io.LimitReader(f, math.MaxInt64) })
+}
+
 // map enc/dec
 func BenchmarkMapEncodeGob(b *testing.B) {
 	var (
@@ -0,0 +1,163 @@
+package encdec
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+var errEnum = errors.New("encdec: unknown enum value")
+
+//  EnumPolicy says what happens to values not registered for an enum
+type EnumPolicy int
+
+const (
+	//  EnumError makes unknown value an encoding or decoding error (default)
+	EnumError EnumPolicy = iota
+	//  EnumKeep keeps unknown value as it is
+	EnumKeep
+	//  EnumDefault decodes unknown value as the default value of enum
+	EnumDefault
+)
+
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+//  Enum is a registered set of named values of integer type T
+//  untagged encoding of enum value is the same as of Int64,
+//  tagged one (KindEnum) carries enum id too, so decoded Value trees print names of values
+type Enum[T integer] struct {
+	id     uint64
+	name   string
+	names  map[T]string
+	values map[string]T
+	policy EnumPolicy
+	def    T
+}
+
+//  enums maps enum ids to names of their values for Value.String
+var enums sync.Map
+
+type enumNames interface {
+	enumName(x int64) string
+}
+
+//  RegisterEnum registers enum type T under id and name with names of its values
+//  registering an id twice or two values of the same name panics
+func RegisterEnum[T integer](id uint64, name string, names map[T]string) *Enum[T] {
+	en := &Enum[T]{id: id, name: name, names: make(map[T]string, len(names)), values: make(map[string]T, len(names))}
+	for v, n := range names {
+		if _, ok := en.values[n]; ok {
+			panic("encdec: duplicate name " + n + " of enum " + name)
+		}
+		en.names[v], en.values[n] = n, v
+	}
+	if _, loaded := enums.LoadOrStore(id, enumNames(en)); loaded {
+		panic(fmt.Sprintf("encdec: enum id %d of %s already registered", id, name))
+	}
+	return en
+}
+
+//  SetPolicy sets handling of unknown values, def is the value EnumDefault decodes them as
+//  it is meant to be called right after RegisterEnum, before the enum is used
+func (en *Enum[T]) SetPolicy(p EnumPolicy, def T) *Enum[T] {
+	en.policy, en.def = p, def
+	return en
+}
+
+//  Name returns name of value v, or enum name with number of unknown value, e.g. Status(7)
+//  it suits String and MarshalText methods of T used by debug and JSON output
+func (en *Enum[T]) Name(v T) string {
+	if n, ok := en.names[v]; ok {
+		return n
+	}
+	return en.name + "(" + strconv.FormatInt(int64(v), 10) + ")"
+}
+
+//  Parse returns value of given name
+func (en *Enum[T]) Parse(name string) (T, error) {
+	if v, ok := en.values[name]; ok {
+		return v, nil
+	}
+	return en.def, errEnum
+}
+
+//  Values returns registered values in ascending order
+func (en *Enum[T]) Values() []T {
+	vs := make([]T, 0, len(en.names))
+	for v := range en.names {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i] < vs[j] })
+	return vs
+}
+
+//  Valid reports whether v is a registered value
+func (en *Enum[T]) Valid(v T) bool {
+	_, ok := en.names[v]
+	return ok
+}
+
+func (en *Enum[T]) enumName(x int64) string {
+	if n, ok := en.names[T(x)]; ok && int64(T(x)) == x {
+		return en.name + "." + n
+	}
+	return en.name + "(" + strconv.FormatInt(x, 10) + ")"
+}
+
+//  Encode encodes value v of enum, unknown value is an encoding error with EnumError policy
+func (en *Enum[T]) Encode(e *Enc, v T) {
+	if e.err != nil {
+		return
+	}
+	if en.policy == EnumError && !en.Valid(v) {
+		e.err = errEnum
+		return
+	}
+	if !e.tagged {
+		e.Int64(int64(v))
+		return
+	}
+	e.tag(KindEnum)
+	e.uvarint(en.id)
+	e.varint(int64(v))
+}
+
+//  Decode decodes value of enum and validates it according to policy
+func (en *Enum[T]) Decode(d *Dec) T {
+	var x int64
+	if !d.tagged {
+		x = d.Int64()
+	} else if d.tag(KindEnum) {
+		if d.uvarint() != en.id && d.err == nil {
+			d.err = errKindMismatch
+		}
+		x = d.varint()
+	}
+	if d.err != nil {
+		return en.def
+	}
+	v := T(x)
+	switch {
+	case int64(v) == x && en.Valid(v):
+		return v
+	case en.policy == EnumDefault:
+		return en.def
+	case en.policy == EnumKeep && int64(v) == x:
+		return v
+	}
+	// value out of range of T can not be kept
+	d.err = errEnum
+	return en.def
+}
+
+//  enumName returns name of value x of enum id for Value.String
+func enumName(id uint64, x int64) string {
+	if en, ok := enums.Load(id); ok {
+		return en.(enumNames).enumName(x)
+	}
+	return "enum" + strconv.FormatUint(id, 10) + "(" + strconv.FormatInt(x, 10) + ")"
+}
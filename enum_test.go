@@ -0,0 +1,103 @@
+package encdec
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testStatus uint8
+
+const (
+	statusActive testStatus = iota + 1
+	statusBlocked
+)
+
+var testStatusEnum = RegisterEnum(1, "Status", map[testStatus]string{statusActive: "active", statusBlocked: "blocked"})
+
+func TestEnum(t *testing.T) {
+	if testStatusEnum.Name(statusBlocked) != "blocked" || testStatusEnum.Name(7) != "Status(7)" {
+		t.Errorf("expected: blocked and got: %v", testStatusEnum.Name(statusBlocked))
+	}
+	if v, err := testStatusEnum.Parse("active"); v != statusActive || err != nil {
+		t.Errorf("expected: %v and got: %v, %v", statusActive, v, err)
+	}
+	if _, err := testStatusEnum.Parse("x"); err != errEnum {
+		t.Errorf("expected: %v and got: %v", errEnum, err)
+	}
+	if vs := testStatusEnum.Values(); !reflect.DeepEqual(vs, []testStatus{statusActive, statusBlocked}) {
+		t.Errorf("expected: [1 2] and got: %v", vs)
+	}
+
+	//untagged enums are Int64
+	enc := NewEnc()
+	testStatusEnum.Encode(enc, statusBlocked)
+	enc.Int64(3)
+	enc.Int64(300)
+	data := enc.Bytes()
+	if dec := NewDec(data); dec.Int64() != int64(statusBlocked) {
+		t.Errorf("expected: %v and got: %v", statusBlocked, dec.Error())
+	}
+	en := RegisterEnum(2, "Policy", map[testStatus]string{statusActive: "active", statusBlocked: "blocked"})
+	for _, c := range []struct {
+		policy   EnumPolicy
+		expected []testStatus
+		err      error
+	}{
+		{EnumError, []testStatus{statusBlocked, statusActive}, errEnum},
+		{EnumKeep, []testStatus{statusBlocked, 3, statusActive}, errEnum},
+		{EnumDefault, []testStatus{statusBlocked, statusActive, statusActive}, nil},
+	} {
+		en.SetPolicy(c.policy, statusActive)
+		dec := NewDec(data)
+		var got []testStatus
+		for dec.Len() > 0 && dec.Error() == nil {
+			got = append(got, en.Decode(dec))
+		}
+		if !reflect.DeepEqual(got, c.expected) || dec.Error() != c.err {
+			t.Errorf("expected: %v, %v and got: %v, %v", c.expected, c.err, got, dec.Error())
+		}
+	}
+	en.SetPolicy(EnumError, 0)
+	if en.Encode(enc, 3); enc.Error() != errEnum {
+		t.Errorf("expected: %v and got: %v", errEnum, enc.Error())
+	}
+	enc.Reset()
+	en.SetPolicy(EnumKeep, 0)
+	if en.Encode(enc, 3); enc.Error() != nil {
+		t.Errorf("expected: nil and got: %v", enc.Error())
+	}
+
+	//tagged enums carry their id, decoded values print names
+	enc = NewEnc()
+	enc.SetTagged(true)
+	testStatusEnum.Encode(enc, statusBlocked)
+	testStatusEnum.Encode(enc, statusActive)
+	enc.Int64(1)
+	dec := NewDec(enc.Bytes())
+	dec.SetTagged(true)
+	if v := DecodeValue(dec); v.String() != "(Status.blocked, Status.active, 1)" || v.Index(0).Enum() != 1 || v.Index(0).Int() != 2 {
+		t.Errorf("expected: (Status.blocked, Status.active, 1) and got: %v", v)
+	}
+	if s := EnumValue(1, 9).String() + EnumValue(99, 1).String(); s != "Status(9)enum99(1)" {
+		t.Errorf("expected: Status(9)enum99(1) and got: %v", s)
+	}
+	dec.Reset()
+	dec.Skip()
+	if testStatusEnum.Decode(dec); dec.Error() != nil {
+		t.Errorf("expected: nil and got: %v", dec.Error())
+	}
+	for _, f := range []func(){func() { dec.Int64() }, func() { en.Decode(dec) }, func() { dec.Skip(); dec.Skip(); testStatusEnum.Decode(dec) }} {
+		dec.Reset()
+		f()
+		if dec.Error() != errKindMismatch {
+			t.Errorf("expected: %v and got: %v", errKindMismatch, dec.Error())
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected: panic got: nil")
+		}
+	}()
+	RegisterEnum(1, "Status", map[testStatus]string{})
+}
@@ -0,0 +1,201 @@
+package encdec
+
+import (
+	"io"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+//  Chunk describes a continuous run of items encoded by EncodeParallel
+type Chunk struct {
+	First  int   // index of the first item
+	Count  int   // number of items
+	Offset int64 // position of encoded chunk in output
+	Len    int   // length of encoded chunk
+}
+
+//  ChunkIndex lists chunks of a parallel encoded stream in output order
+type ChunkIndex []Chunk
+
+//  chunksPerWorker keeps workers busy while the output is written strictly in order
+const chunksPerWorker = 4
+
+//  maxChunkItems limits items of a chunk, so that chunks waiting for the writer hold bounded amount of memory
+const maxChunkItems = 1024
+
+//  EncodeParallel encodes n items by calling fn for each of them on multiple goroutines
+//  items are split into chunks encoded into separate encoders and written to w in original order,
+//  so the output is the same as of a serial encoding
+//  workers < 1 means runtime.GOMAXPROCS(0) workers
+func EncodeParallel(w io.Writer, n int, fn func(i int, e *Enc), workers int) (ChunkIndex, error) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if n <= 0 {
+		return ChunkIndex{}, nil
+	}
+	size := (n + workers*chunksPerWorker - 1) / (workers * chunksPerWorker)
+	if size > maxChunkItems {
+		size = maxChunkItems
+	}
+	idx := make(ChunkIndex, (n+size-1)/size)
+	for c := range idx {
+		idx[c].First = c * size
+		idx[c].Count = size
+		if c == len(idx)-1 {
+			idx[c].Count = n - idx[c].First
+		}
+	}
+
+	results := make([]chan *Enc, len(idx))
+	for c := range results {
+		results[c] = make(chan *Enc, 1)
+	}
+	// bounds number of chunks encoded ahead of the writer, a slow chunk stops dispatching of further ones
+	pending := make(chan struct{}, workers*chunksPerWorker)
+	jobs := make(chan int)
+	stop := make(chan struct{})
+	pool := sync.Pool{New: func() interface{} { return NewEnc() }}
+	var wg sync.WaitGroup
+
+	go func() {
+		defer close(jobs)
+		for c := range idx {
+			select {
+			case pending <- struct{}{}:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- c:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for k := 0; k < workers; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				e := pool.Get().(*Enc)
+				e.Reset()
+				for i := idx[c].First; i < idx[c].First+idx[c].Count && e.err == nil; i++ {
+					fn(i, e)
+				}
+				results[c] <- e
+			}
+		}()
+	}
+
+	var (
+		off int64
+		err error
+	)
+	for c := range idx {
+		e := <-results[c]
+		err = e.err
+		if err == nil {
+			idx[c].Offset = off
+			idx[c].Len = e.Len()
+			var m int64
+			m, err = e.WriteTo(w)
+			off += m
+		}
+		pool.Put(e)
+		<-pending
+		if err != nil {
+			break
+		}
+	}
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+//  DecodeParallel decodes items of a stream produced by EncodeParallel by calling fn for each of them on multiple goroutines
+//  every chunk is decoded by its own decoder, the first error in chunk order is returned
+//  workers < 1 means runtime.GOMAXPROCS(0) workers
+func DecodeParallel(data []byte, idx ChunkIndex, fn func(i int, d *Dec), workers int) error {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	errs := make([]error, len(idx))
+	jobs := make(chan int)
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Bool
+	)
+	for k := 0; k < workers; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				ch := idx[c]
+				if ch.Offset < 0 || ch.Len < 0 || ch.Offset > int64(len(data)) || int64(ch.Len) > int64(len(data))-ch.Offset {
+					errs[c] = errDecodeNotEnoughtData
+					failed.Store(true)
+					continue
+				}
+				d := NewDec(data[ch.Offset : ch.Offset+int64(ch.Len)])
+				for i := ch.First; i < ch.First+ch.Count && d.err == nil; i++ {
+					fn(i, d)
+				}
+				if errs[c] = d.err; d.err != nil {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	// chunks are dispatched in order, so all chunks before a failed one are decoded
+	for c := 0; c < len(idx) && !failed.Load(); c++ {
+		jobs <- c
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//  MarshalBinary implements encoding.BinaryMarshaler
+func (idx ChunkIndex) MarshalBinary() ([]byte, error) {
+	enc := NewEnc()
+	enc.Uint64(uint64(len(idx)))
+	for _, c := range idx {
+		enc.Uint64(uint64(c.First))
+		enc.Uint64(uint64(c.Count))
+		enc.Uint64(uint64(c.Offset))
+		enc.Uint64(uint64(c.Len))
+	}
+	return enc.Bytes(), enc.Error()
+}
+
+//  UnmarshalBinary implements encoding.BinaryUnmarshaler
+func (idx *ChunkIndex) UnmarshalBinary(data []byte) error {
+	dec := NewDec(data)
+	l := dec.Uint64()
+	if l > uint64(len(data)) {
+		return errDecode
+	}
+	*idx = make(ChunkIndex, l)
+	for c := range *idx {
+		(*idx)[c] = Chunk{
+			First:  int(dec.Uint64()),
+			Count:  int(dec.Uint64()),
+			Offset: int64(dec.Uint64()),
+			Len:    int(dec.Uint64())}
+		if c := (*idx)[c]; c.First < 0 || c.Count < 0 || c.First > math.MaxInt-c.Count || c.Offset < 0 || c.Len < 0 {
+			return errDecode
+		}
+	}
+	return dec.Error()
+}
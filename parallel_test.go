@@ -0,0 +1,122 @@
+package encdec
+
+import (
+	"bytes"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeParallel(t *testing.T) {
+	const n = 10000
+	items := make([]testType, n)
+	for i := range items {
+		items[i] = newTestType()
+		items[i].A = i
+	}
+	serial := NewEnc()
+	for i := range items {
+		serial.Marshaler(&items[i])
+	}
+
+	for _, workers := range []int{0, 1, 3, 16} {
+		var w bytes.Buffer
+		idx, err := EncodeParallel(&w, n, func(i int, e *Enc) {
+			e.Marshaler(&items[i])
+		}, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(w.Bytes(), serial.Bytes()) {
+			t.Fatalf("workers %v: parallel output differs from serial one", workers)
+		}
+
+		//index survives marshalling
+		buf, err := idx.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var idx2 ChunkIndex
+		if err = idx2.UnmarshalBinary(buf); err != nil || len(idx2) != len(idx) {
+			t.Fatalf("expected: %v and got: %v, %v", idx, idx2, err)
+		}
+
+		got := make([]testType, n)
+		err = DecodeParallel(w.Bytes(), idx2, func(i int, d *Dec) {
+			d.Unmarshaler(&got[i])
+		}, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range got {
+			if got[i].A != i || !got[i].D.Equal(items[i].D) {
+				t.Fatalf("item %v: expected: %v and got: %v", i, items[i], got[i])
+			}
+		}
+	}
+
+	//errors
+	_, err := EncodeParallel(&bytes.Buffer{}, n, func(i int, e *Enc) {
+		if i == n/2 {
+			e.ByteSlice(nil)
+		}
+		e.Uint64(1)
+	}, 4)
+	if err != errEncode {
+		t.Errorf("expected: %v and got: %v", errEncode, err)
+	}
+	if err = DecodeParallel([]byte{}, ChunkIndex{{0, 1, 0, 10}}, func(i int, d *Dec) {}, 1); err != errDecodeNotEnoughtData {
+		t.Errorf("expected: %v and got: %v", errDecodeNotEnoughtData, err)
+	}
+	if idx, err := EncodeParallel(&bytes.Buffer{}, 0, nil, 1); err != nil || len(idx) != 0 {
+		t.Errorf("expected: empty index and got: %v, %v", idx, err)
+	}
+
+	//crafted index
+	buf, _ := ChunkIndex{{0, 1, 1<<63 - 5, 10}}.MarshalBinary()
+	var crafted ChunkIndex
+	if err = crafted.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err = DecodeParallel(make([]byte, 16), crafted, func(i int, d *Dec) {}, 1); err != errDecodeNotEnoughtData {
+		t.Errorf("expected: %v and got: %v", errDecodeNotEnoughtData, err)
+	}
+	for _, c := range []Chunk{{-1, 1, 0, 0}, {0, -1, 0, 0}, {1, math.MaxInt, 0, 0}, {0, 1, -1, 0}, {0, 1, 0, -1}} {
+		buf, _ = ChunkIndex{c}.MarshalBinary()
+		if err = crafted.UnmarshalBinary(buf); err != errDecode {
+			t.Errorf("%v: expected: %v and got: %v", c, errDecode, err)
+		}
+	}
+
+	//slow first chunk holds back encoding of the rest
+	var encoded atomic.Int64
+	release := make(chan struct{})
+	ahead := int64(0)
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	_, err = EncodeParallel(&bytes.Buffer{}, 100*maxChunkItems, func(i int, e *Enc) {
+		if i == 0 {
+			<-release
+			ahead = encoded.Load()
+		}
+		encoded.Add(1)
+		e.Uint64(uint64(i))
+	}, 2)
+	if err != nil || ahead > 2*chunksPerWorker*maxChunkItems {
+		t.Errorf("expected: at most %v items encoded ahead and got: %v, %v", 2*chunksPerWorker*maxChunkItems, ahead, err)
+	}
+
+	//decoding stops dispatching chunks after an error
+	var w bytes.Buffer
+	idx, _ := EncodeParallel(&w, 100*maxChunkItems, func(i int, e *Enc) { e.Uint64(uint64(i)) }, 2)
+	var decoded atomic.Int64
+	err = DecodeParallel(w.Bytes(), idx, func(i int, d *Dec) {
+		decoded.Add(1)
+		if d.Uint64(); i == 0 {
+			d.err = errDecode
+		}
+	}, 1)
+	if err != errDecode || decoded.Load() > 2*maxChunkItems {
+		t.Errorf("expected: %v after at most %v items and got: %v after %v", errDecode, 2*maxChunkItems, err, decoded.Load())
+	}
+}
@@ -0,0 +1,140 @@
+package encdec
+
+import (
+	"bufio"
+	"context"
+	"encoding"
+	"encoding/binary"
+	"io"
+	"runtime"
+)
+
+//  Record is a decoded record delivered by DecodePipeline
+type Record struct {
+	Index int                         // position of the record in the stream
+	Value encoding.BinaryUnmarshaler // decoded value, nil if Err is set
+	Err   error
+}
+
+//  DecodePipeline decodes a stream of records written by Enc.Marshaler
+//  the stream is split into records using their length prefixes only, payloads are unmarshalled
+//  by workers into values created by newFn and delivered over returned channel in original order
+//  the channel is closed at the end of stream, after the first error (delivered as Record.Err) or when ctx is done,
+//  a consumer that stops early has to cancel ctx to release pipeline goroutines
+//  workers < 1 means runtime.GOMAXPROCS(0) workers
+func DecodePipeline(ctx context.Context, r io.Reader, newFn func() encoding.BinaryUnmarshaler, workers int) <-chan Record {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	// delivery stops the splitter and workers when it is done
+	ctx, cancel := context.WithCancel(ctx)
+	type job struct {
+		rec  Record
+		data []byte
+		res  chan Record
+	}
+	jobs := make(chan job)
+	order := make(chan chan Record, workers*chunksPerWorker)
+	out := make(chan Record)
+
+	// splitter
+	go func() {
+		defer close(jobs)
+		defer close(order)
+		defer watchDeadline(ctx, r, false)()
+		br := bufio.NewReader(ctxReader{ctx: ctx, r: r})
+		for i := 0; ; i++ {
+			data, err := readRecord(br)
+			if err == io.EOF {
+				return
+			}
+			j := job{rec: Record{Index: i, Err: err}, data: data, res: make(chan Record, 1)}
+			select {
+			case order <- j.res:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				j.res <- j.rec
+				return
+			}
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	// workers
+	for k := 0; k < workers; k++ {
+		go func() {
+			for j := range jobs {
+				v := newFn()
+				if j.rec.Err = v.UnmarshalBinary(j.data); j.rec.Err == nil {
+					j.rec.Value = v
+				}
+				j.res <- j.rec
+			}
+		}()
+	}
+	// ordered delivery
+	go func() {
+		defer close(out)
+		defer cancel()
+		for res := range order {
+			var rec Record
+			select {
+			case rec = <-res:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case out <- rec:
+			case <-ctx.Done():
+				return
+			}
+			if rec.Err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+//  maxRecordPrealloc is the biggest record payload allocated before it is actually read
+const maxRecordPrealloc = 1 << 16
+
+//  readRecord reads payload of the next length prefixed record, io.EOF is returned only at record boundary
+func readRecord(br *bufio.Reader) ([]byte, error) {
+	lng, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if lng == 0 || lng > binary.MaxVarintLen64 {
+		return nil, errDecode
+	}
+	var buf [binary.MaxVarintLen64]byte
+	if _, err = io.ReadFull(br, buf[:lng]); err != nil {
+		return nil, errDecodeNotEnoughtData
+	}
+	l, n := binary.Uvarint(buf[:lng])
+	if n <= 0 {
+		return nil, errDecode
+	}
+	if l > maxRecordPrealloc {
+		// length prefix is not trusted with a big allocation
+		data, err := io.ReadAll(io.LimitReader(br, int64(l)))
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(data)) < l {
+			return nil, errDecodeNotEnoughtData
+		}
+		return data, nil
+	}
+	data := make([]byte, l)
+	if _, err = io.ReadFull(br, data); err != nil {
+		return nil, errDecodeNotEnoughtData
+	}
+	return data, nil
+}
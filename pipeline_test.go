@@ -0,0 +1,89 @@
+package encdec
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDecodePipeline(t *testing.T) {
+	const n = 5000
+	enc := NewEnc()
+	for i := 0; i < n; i++ {
+		v := newTestType()
+		v.A = i
+		v.C = string(bytes.Repeat([]byte{'x'}, i%100))
+		enc.Marshaler(&v)
+	}
+	data := enc.Bytes()
+	newFn := func() encoding.BinaryUnmarshaler { return &testType{} }
+
+	i := 0
+	for rec := range DecodePipeline(context.Background(), bytes.NewReader(data), newFn, 4) {
+		if rec.Err != nil {
+			t.Fatal(rec.Err)
+		}
+		if v := rec.Value.(*testType); rec.Index != i || v.A != i || len(v.C) != i%100 {
+			t.Fatalf("expected: %v and got: %v (%v)", i, rec.Index, v.A)
+		}
+		i++
+	}
+	if i != n {
+		t.Errorf("expected: %v records and got: %v", n, i)
+	}
+
+	//truncated stream delivers error in order
+	i = 0
+	var last Record
+	for rec := range DecodePipeline(context.Background(), bytes.NewReader(data[:len(data)-3]), newFn, 4) {
+		last = rec
+		i++
+	}
+	if i != n || last.Err != errDecodeNotEnoughtData || last.Index != n-1 {
+		t.Errorf("expected: %v and got: %v at %v", errDecodeNotEnoughtData, last.Err, last.Index)
+	}
+
+	//unmarshal error stops the pipeline and releases its goroutines
+	goroutines := runtime.NumGoroutine()
+	for k := 0; k < 5; k++ {
+		i = 0
+		for rec := range DecodePipeline(context.Background(), bytes.NewReader(data), func() encoding.BinaryUnmarshaler { return &failingType{} }, 4) {
+			if rec.Err == nil {
+				t.Fatal("expected: error got: nil")
+			}
+			i++
+		}
+		if i != 1 {
+			t.Errorf("expected: 1 record and got: %v", i)
+		}
+	}
+	for k := 0; k < 100 && runtime.NumGoroutine() > goroutines; k++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("expected: %v goroutines and got: %v", goroutines, n)
+	}
+
+	//cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	i = 0
+	for range DecodePipeline(ctx, bytes.NewReader(data), newFn, 4) {
+		i++
+		if i == 10 {
+			cancel()
+		}
+	}
+	if i >= n {
+		t.Error("pipeline was not cancelled")
+	}
+}
+
+type failingType struct{}
+
+func (failingType) UnmarshalBinary(data []byte) error {
+	return errDecode
+}
@@ -0,0 +1,228 @@
+package encdec
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+//  Records are made of numbered fields, so they can evolve: a reader skips fields it does not know
+//  and a field missing in data keeps its default value.
+//  Every field is encoded as Uint64(id) followed by its payload length prefixed like ByteSlice,
+//  the record is closed by an end marker.
+
+//  RawField is an encoded record field kept verbatim, e.g. an unknown field preserved for re-encoding
+type RawField struct {
+	ID   uint64
+	Data []byte
+}
+
+//  decField is an open field of decoded record
+type decField struct {
+	id   uint64
+	end  int // position of the end of field payload, -1 before the first field
+	dict []*dictEntry
+}
+
+//  encField is a field of an open record, its length prefix is inserted when the record ends
+type encField struct {
+	at    int // position of field id
+	start int // position of field payload
+	dict  encTable
+}
+
+//  encRecord is an open record of encoder
+type encRecord struct {
+	seq   int // number of the record, so that checkpoints tell records ended since
+	first int // index of its first field
+}
+
+//  BeginRecord starts encoding of a record
+func (e *Enc) BeginRecord() {
+	if e.err != nil {
+		return
+	}
+	e.nrec++
+	e.records = append(e.records, encRecord{seq: e.nrec, first: len(e.fields)})
+}
+
+//  Field starts field id of actual record, entities encoded until next Field or EndRecord form its payload
+func (e *Enc) Field(id uint64) {
+	if e.err != nil {
+		return
+	}
+	if len(e.records) == 0 {
+		e.err = errEncode
+		return
+	}
+	at := len(e.encbuf)
+	e.Uint64(id)
+	e.fields = append(e.fields, encField{at: at, start: len(e.encbuf)})
+}
+
+//  RawField encodes a field of actual record verbatim
+func (e *Enc) RawField(f RawField) {
+	e.Field(f.ID)
+	if e.err == nil {
+		e.encbuf = append(e.encbuf, f.Data...)
+		e.fields[len(e.fields)-1].dict.raw = true
+	}
+}
+
+//  EndRecord finishes encoding of actual record
+func (e *Enc) EndRecord() {
+	if e.err != nil {
+		return
+	}
+	if len(e.records) == 0 {
+		e.err = errEncode
+		return
+	}
+	r := e.records[len(e.records)-1]
+	e.closeFields(e.fields[r.first:])
+	e.fields = e.fields[:r.first]
+	e.records = e.records[:len(e.records)-1]
+	e.end()
+}
+
+//  closeFields inserts length prefixes in front of payloads of fields fs of record ending at the end of buffer
+//  prefixes are inserted together, so every byte of the record is moved once
+func (e *Enc) closeFields(fs []encField) {
+	if len(fs) == 0 {
+		return
+	}
+	var hdr [2 + binary.MaxVarintLen64]byte
+	end := len(e.encbuf)
+	r0 := sort.Search(len(e.refs), func(i int) bool { return e.refs[i].off >= fs[0].start })
+	shift, r := 0, r0
+	for i := range fs {
+		fend := end
+		if i+1 < len(fs) {
+			fend = fs[i+1].at
+		}
+		n := fend - fs[i].start
+		for ; r < len(e.refs) && e.refs[r].off <= fend; r++ {
+			n += len(e.refs[r].buf)
+		}
+		// position of id is not needed anymore, it keeps payload length
+		fs[i].at = n
+		shift += e.prefix(hdr[:], n)
+	}
+	e.encbuf = append(e.encbuf, make([]byte, shift)...)
+	r = len(e.refs) - 1
+	for i := len(fs) - 1; i >= 0; i-- {
+		// payload of field i and id of the next one move by sum of prefixes up to field i
+		next := end
+		if i+1 < len(fs) {
+			next = fs[i+1].start
+		}
+		copy(e.encbuf[fs[i].start+shift:], e.encbuf[fs[i].start:next])
+		for ; r >= r0 && e.refs[r].off >= fs[i].start; r-- {
+			e.refs[r].off += shift
+		}
+		h := e.prefix(hdr[:], fs[i].at)
+		shift -= h
+		copy(e.encbuf[fs[i].start+shift:], hdr[:h])
+	}
+}
+
+//  prefix writes length prefix of n bytes payload into b like tag(KindBytes) and uvarint do and returns its size
+func (e *Enc) prefix(b []byte, n int) int {
+	h := 0
+	if e.tagged {
+		b[0] = tagBase + byte(KindBytes)
+		h = 1
+	}
+	l := binary.PutUvarint(b[h+1:], uint64(n))
+	b[h] = byte(l)
+	return h + 1 + l
+}
+
+//  BeginRecord starts decoding of a record
+func (d *Dec) BeginRecord() {
+	if d.err != nil {
+		return
+	}
+	d.fields = append(d.fields, decField{end: -1})
+}
+
+//  NextField moves to next field of actual record and returns its id
+//  rest of previous field is skipped, so unknown fields need no handling
+//  false is returned at the end of record (record is finished then) or on error
+func (d *Dec) NextField() (uint64, bool) {
+	if d.err != nil {
+		return 0, false
+	}
+	if len(d.fields) == 0 {
+		d.err = errDecode
+		return 0, false
+	}
+	f := &d.fields[len(d.fields)-1]
+	if f.end >= 0 {
+		if d.Pos() > f.end {
+			// field decoded beyond its payload
+			d.err = errDecode
+			return 0, false
+		}
+		d.advance(f.end - d.Pos())
+		f.end = -1
+		f.dict = nil
+	}
+	if d.end() {
+		d.fields = d.fields[:len(d.fields)-1]
+		return 0, false
+	}
+	f.id = d.Uint64()
+	if !d.tag(KindBytes) {
+		return 0, false
+	}
+	n := d.uvarint()
+	if d.err != nil {
+		return 0, false
+	}
+	if n > uint64(d.Len()) {
+		d.err = errDecodeNotEnoughtData
+		return 0, false
+	}
+	f.end = d.Pos() + int(n)
+	return f.id, true
+}
+
+//  RawField returns actual field verbatim, e.g. to preserve an unknown field
+//  in dictionary mode it is a decoding error once decoded part of the field has added to the table
+func (d *Dec) RawField() RawField {
+	if d.err != nil || len(d.fields) == 0 || d.fields[len(d.fields)-1].end < d.Pos() || len(d.fields[len(d.fields)-1].dict) > 0 {
+		if d.err == nil {
+			d.err = errDecode
+		}
+		return RawField{}
+	}
+	f := d.fields[len(d.fields)-1]
+	n := f.end - d.Pos()
+	d.need(n)
+	data := d.copied(d.decbuf[d.i : d.i+n])
+	d.i += n
+	return RawField{ID: f.id, Data: data}
+}
+
+//  EndRecord skips remaining fields of actual record and finishes it
+func (d *Dec) EndRecord() {
+	if d.err != nil || len(d.fields) == 0 {
+		return
+	}
+	depth := len(d.fields)
+	for _, ok := d.NextField(); ok; _, ok = d.NextField() {
+	}
+	if d.err == nil && len(d.fields) >= depth {
+		d.err = errDecode
+	}
+}
+
+//  advance skips n bytes of undecoded data
+func (d *Dec) advance(n int) {
+	for n > len(d.decbuf)-d.i && len(d.chunks) > 0 {
+		n -= len(d.decbuf) - d.i
+		d.i = len(d.decbuf)
+		d.stitch(1)
+	}
+	d.i += n
+}
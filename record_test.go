@@ -0,0 +1,159 @@
+package encdec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	blob := bytes.Repeat([]byte{9}, 100)
+	for _, tagged := range []bool{false, true} {
+		//version 2 writer
+		enc := NewEnc()
+		enc.SetTagged(tagged)
+		enc.SetVectorThreshold(50)
+		enc.BeginRecord()
+		enc.Field(1)
+		enc.Str("John")
+		enc.Field(3) //unknown to version 1
+		enc.ByteSlice(blob)
+		enc.Uint64(7)
+		enc.Field(4) //nested record
+		enc.BeginRecord()
+		enc.Field(1)
+		enc.Int64(-1)
+		enc.EndRecord()
+		enc.EndRecord()
+		enc.Uint64(99)
+		if enc.Error() != nil {
+			t.Fatal(enc.Error())
+		}
+		data := enc.Bytes()
+
+		//version 1 reader: knows field 1 and 2, keeps unknown ones
+		dec := NewDec(data)
+		dec.SetTagged(tagged)
+		name, age := "", int64(18)
+		var unknown []RawField
+		dec.BeginRecord()
+		for id, ok := dec.NextField(); ok; id, ok = dec.NextField() {
+			switch id {
+			case 1:
+				name = dec.Str()
+			case 2:
+				age = dec.Int64()
+			default:
+				unknown = append(unknown, dec.RawField())
+			}
+		}
+		if dec.Error() != nil || name != "John" || age != 18 || len(unknown) != 2 || dec.Uint64() != 99 {
+			t.Fatalf("tagged %v: decoding failed: %v", tagged, dec.Error())
+		}
+
+		//lossless round trip through version 1
+		re := NewEnc()
+		re.SetTagged(tagged)
+		re.BeginRecord()
+		re.Field(1)
+		re.Str(name)
+		for _, f := range unknown {
+			re.RawField(f)
+		}
+		re.EndRecord()
+		re.Uint64(99)
+		if !bytes.Equal(re.Bytes(), data) {
+			t.Fatalf("tagged %v: expected: %v and got: %v", tagged, data, re.Bytes())
+		}
+
+		//version 2 reader leaving early
+		dec = NewDecChunks([][]byte{data[:5], data[5:50], data[50:]})
+		dec.SetTagged(tagged)
+		dec.BeginRecord()
+		dec.NextField()
+		dec.NextField()
+		if !bytes.Equal(dec.ByteSlice(), blob) {
+			t.Errorf("tagged %v: field 3 decoding failed: %v", tagged, dec.Error())
+		}
+		dec.EndRecord()
+		if dec.Uint64() != 99 || dec.Error() != nil {
+			t.Errorf("tagged %v: expected: 99 and got: %v", tagged, dec.Error())
+		}
+	}
+
+	//field decoded beyond its payload
+	enc := NewEnc()
+	enc.BeginRecord()
+	enc.Field(1)
+	enc.Uint64(1)
+	enc.Field(2)
+	enc.Uint64(2)
+	enc.EndRecord()
+	dec := NewDec(enc.Bytes())
+	dec.BeginRecord()
+	dec.NextField()
+	dec.Uint64()
+	dec.Uint64()
+	if _, ok := dec.NextField(); ok || dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+
+	//misuse
+	enc.Reset()
+	enc.Field(1)
+	if enc.Error() != errEncode {
+		t.Errorf("expected: %v and got: %v", errEncode, enc.Error())
+	}
+	dec.Reset()
+	if _, ok := dec.NextField(); ok || dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+
+	//rollback of optional fields
+	for _, tagged := range []bool{false, true} {
+		exp := NewEnc()
+		exp.SetTagged(tagged)
+		exp.BeginRecord()
+		exp.Field(1)
+		exp.Uint64(1)
+		exp.Field(3)
+		exp.ByteSlice(blob)
+		exp.EndRecord()
+
+		enc = NewEnc()
+		enc.SetTagged(tagged)
+		enc.SetVectorThreshold(50)
+		enc.BeginRecord()
+		cp := enc.Checkpoint()
+		enc.Field(1)
+		enc.Uint64(2)
+		enc.Rollback(cp)
+		enc.Field(1)
+		enc.Uint64(1)
+		cp = enc.Checkpoint()
+		enc.Field(2)
+		enc.BeginRecord()
+		enc.Field(1)
+		enc.ByteSlice(blob)
+		enc.EndRecord()
+		enc.ByteSlice(nil)
+		enc.Rollback(cp)
+		enc.Field(3)
+		enc.ByteSlice(blob)
+		enc.EndRecord()
+		if enc.Error() != nil || !bytes.Equal(enc.Bytes(), exp.Bytes()) {
+			t.Errorf("tagged %v: expected: %v and got: %v, %v", tagged, exp.Bytes(), enc.Bytes(), enc.Error())
+		}
+	}
+
+	//rollback into ended record
+	enc.Reset()
+	enc.BeginRecord()
+	enc.Field(1)
+	cp := enc.Checkpoint()
+	enc.EndRecord()
+	enc.BeginRecord()
+	enc.Rollback(cp)
+	if enc.Error() != errEncode {
+		t.Errorf("expected: %v and got: %v", errEncode, enc.Error())
+	}
+}
@@ -0,0 +1,116 @@
+package encdec
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+)
+
+var errUnregistered = errors.New("encdec: unregistered type")
+
+//  registry maps type ids of Enc.Any and Dec.Any to registered types
+var registry struct {
+	sync.RWMutex
+	byID   map[uint64]*registered
+	byType map[reflect.Type]*registered
+}
+
+type registered struct {
+	id   uint64
+	name string
+	new  func() encoding.BinaryUnmarshaler
+}
+
+//  Register registers type T under id, so that Enc.Any can encode T or *T values and Dec.Any decode them as *T
+//  id 0 is reserved for nil, registering an id or a type twice panics
+func Register[T any, PT interface {
+	*T
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}](id uint64) {
+	register[T, PT](id, reflect.TypeOf((*T)(nil)).Elem().String())
+}
+
+//  RegisterName registers type T like Register under id derived from name (64-bit FNV-1a hash),
+//  stable names need no central allocation of ids
+func RegisterName[T any, PT interface {
+	*T
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}](name string) {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	register[T, PT](h.Sum64(), name)
+}
+
+func register[T any, PT interface {
+	*T
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}](id uint64, name string) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.byID == nil {
+		registry.byID = make(map[uint64]*registered)
+		registry.byType = make(map[reflect.Type]*registered)
+	}
+	if id == 0 {
+		panic("encdec: type id 0 is reserved for nil")
+	}
+	if r := registry.byID[id]; r != nil {
+		panic(fmt.Sprintf("encdec: type id %d of %s already registered by %s", id, name, r.name))
+	}
+	if r := registry.byType[t]; r != nil {
+		panic(fmt.Sprintf("encdec: type %s already registered as %s", t, r.name))
+	}
+	r := &registered{id: id, name: name, new: func() encoding.BinaryUnmarshaler { return PT(new(T)) }}
+	registry.byID[id] = r
+	registry.byType[t] = r
+	registry.byType[reflect.PtrTo(t)] = r
+}
+
+//  Any encodes x of a registered type preceded by its type id, nil or nil pointer is encoded as id 0
+func (e *Enc) Any(x encoding.BinaryMarshaler) {
+	if e.err != nil {
+		return
+	}
+	if v := reflect.ValueOf(x); x == nil || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		e.Uint64(0)
+		return
+	}
+	registry.RLock()
+	r := registry.byType[reflect.TypeOf(x)]
+	registry.RUnlock()
+	if r == nil {
+		e.err = errUnregistered
+		return
+	}
+	e.Uint64(r.id)
+	e.Marshaler(x)
+}
+
+//  Any decodes a value encoded by Enc.Any as a pointer to new value of its registered type
+//  unregistered type id is a decoding error
+func (d *Dec) Any() interface{} {
+	id := d.Uint64()
+	if d.err != nil || id == 0 {
+		return nil
+	}
+	registry.RLock()
+	r := registry.byID[id]
+	registry.RUnlock()
+	if r == nil {
+		d.err = errUnregistered
+		return nil
+	}
+	x := r.new()
+	d.Unmarshaler(x)
+	if d.err != nil {
+		return nil
+	}
+	return x
+}
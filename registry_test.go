@@ -0,0 +1,120 @@
+package encdec
+
+import (
+	"encoding"
+	"reflect"
+	"testing"
+)
+
+type testEvent interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+	Name() string
+}
+
+type loginEvent struct {
+	user string
+}
+
+func (e *loginEvent) Name() string { return "login" }
+
+func (e *loginEvent) MarshalBinary() ([]byte, error) {
+	enc := NewEnc()
+	enc.Str(e.user)
+	return enc.Bytes(), enc.Error()
+}
+
+func (e *loginEvent) UnmarshalBinary(data []byte) error {
+	dec := NewDec(data)
+	e.user = dec.Str()
+	return dec.Error()
+}
+
+type transferEvent struct {
+	from, to string
+	amount   int64
+}
+
+func (e transferEvent) Name() string { return "transfer" }
+
+func (e transferEvent) MarshalBinary() ([]byte, error) {
+	enc := NewEnc()
+	enc.Str(e.from)
+	enc.Str(e.to)
+	enc.Int64(e.amount)
+	return enc.Bytes(), enc.Error()
+}
+
+func (e *transferEvent) UnmarshalBinary(data []byte) error {
+	dec := NewDec(data)
+	e.from, e.to, e.amount = dec.Str(), dec.Str(), dec.Int64()
+	return dec.Error()
+}
+
+type unregisteredEvent struct{ loginEvent }
+
+func init() {
+	Register[loginEvent](1)
+	RegisterName[transferEvent]("bank.Transfer")
+}
+
+func TestAny(t *testing.T) {
+	events := []encoding.BinaryMarshaler{&loginEvent{"john"}, transferEvent{"john", "jane", 10}, &transferEvent{"jane", "john", 5}, nil, (*loginEvent)(nil)}
+	for _, tagged := range []bool{false, true} {
+		enc := NewEnc()
+		enc.SetTagged(tagged)
+		enc.Uint64(uint64(len(events)))
+		for _, ev := range events {
+			enc.Any(ev)
+		}
+		dec := NewDec(enc.Bytes())
+		dec.SetTagged(tagged)
+		n := int(dec.Uint64())
+		var got []interface{}
+		for i := 0; i < n; i++ {
+			got = append(got, dec.Any())
+		}
+		if dec.Error() != nil || len(got) != 5 {
+			t.Fatal(dec.Error())
+		}
+		if ev, ok := got[0].(testEvent); !ok || ev.Name() != "login" || !reflect.DeepEqual(ev, events[0]) {
+			t.Errorf("expected: %v and got: %v", events[0], got[0])
+		}
+		if ev, ok := got[1].(*transferEvent); !ok || *ev != events[1] || !reflect.DeepEqual(got[2], events[2]) {
+			t.Errorf("expected: %v and got: %v", events[1:3], got[1:3])
+		}
+		if got[3] != nil || got[4] != nil {
+			t.Errorf("expected: nil and got: %v", got[3:])
+		}
+	}
+
+	//unregistered types
+	enc := NewEnc()
+	enc.Any(&unregisteredEvent{})
+	if enc.Error() != errUnregistered {
+		t.Errorf("expected: %v and got: %v", errUnregistered, enc.Error())
+	}
+	enc.Reset()
+	enc.Uint64(2)
+	enc.Marshaler(&loginEvent{"john"})
+	dec := NewDec(enc.Bytes())
+	if x := dec.Any(); x != nil || dec.Error() != errUnregistered {
+		t.Errorf("expected: %v and got: %v, %v", errUnregistered, x, dec.Error())
+	}
+
+	//duplicate registrations
+	for _, f := range []func(){
+		func() { Register[unregisteredEvent](1) },
+		func() { Register[loginEvent](3) },
+		func() { Register[unregisteredEvent](0) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected: panic got: nil")
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,381 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/mrkovec/encdec"
+)
+
+//  Marshal encodes v according to message m
+//  v is a Go struct, a pointer to it or an encdec.Value message holding fields in order of their numbers
+func (m *Message) Marshal(v interface{}) ([]byte, error) {
+	mv, err := m.value(v)
+	if err != nil {
+		return nil, err
+	}
+	enc := encdec.NewEnc()
+	if err = m.encode(enc, mv); err != nil {
+		return nil, err
+	}
+	return enc.Bytes(), enc.Error()
+}
+
+//  Unmarshal decodes data according to message m into v, a pointer to Go struct or to encdec.Value
+//  data must hold exactly one message, trailing bytes are a mismatch
+func (m *Message) Unmarshal(data []byte, v interface{}) error {
+	dec := encdec.NewDec(data)
+	mv, err := m.decode(dec, 0)
+	if err != nil {
+		return err
+	}
+	if dec.Error() != nil {
+		return dec.Error()
+	}
+	if dec.Len() != 0 {
+		return errMismatch
+	}
+	if p, ok := v.(*encdec.Value); ok {
+		*p = mv
+		return nil
+	}
+	return m.setStruct(mv, v)
+}
+
+//  encode encodes message value mv
+func (m *Message) encode(enc *encdec.Enc, mv encdec.Value) error {
+	if m.Record {
+		return m.encodeRecord(enc, mv)
+	}
+	if m.Sparse {
+		return m.encodeSparse(enc, mv)
+	}
+	if mv.Kind() != encdec.KindNested || mv.Len() != len(m.Fields) {
+		return errMismatch
+	}
+	for i, f := range m.Fields {
+		if err := encodeType(enc, f.Type, mv.Index(i)); err != nil {
+			return err
+		}
+	}
+	return enc.Error()
+}
+
+//  encodeRecord encodes record value mv, null fields are left out
+//  unknown fields of its trailing map are merged with known ones in order of numbers
+func (m *Message) encodeRecord(enc *encdec.Enc, mv encdec.Value) error {
+	if mv.Kind() != encdec.KindNested {
+		return errMismatch
+	}
+	var unknown encdec.Value
+	if mv.Len() == len(m.Fields)+1 {
+		unknown = mv.Index(len(m.Fields))
+	}
+	if mv.Len() != len(m.Fields) && unknown.Kind() != encdec.KindMap {
+		return errMismatch
+	}
+	raw := func(u int) error {
+		id, data := unknown.Key(u), unknown.Elem(u)
+		if id.Kind() != encdec.KindUint || data.Kind() != encdec.KindBytes || m.index(id.Uint()) >= 0 {
+			return errMismatch
+		}
+		enc.RawField(encdec.RawField{ID: id.Uint(), Data: data.Bytes()})
+		return nil
+	}
+	enc.BeginRecord()
+	u := 0
+	for i, f := range m.Fields {
+		for ; u < unknown.Len() && unknown.Key(u).Uint() < uint64(f.Number); u++ {
+			if err := raw(u); err != nil {
+				return err
+			}
+		}
+		v := mv.Index(i)
+		if v.Kind() == encdec.KindNull && !f.Required {
+			continue
+		}
+		enc.Field(uint64(f.Number))
+		if err := encodeType(enc, f.Type, v); err != nil {
+			return err
+		}
+	}
+	for ; u < unknown.Len(); u++ {
+		if err := raw(u); err != nil {
+			return err
+		}
+	}
+	enc.EndRecord()
+	return enc.Error()
+}
+
+//  encodeSparse encodes presence bitmap of non-zero fields of mv followed by those fields
+func (m *Message) encodeSparse(enc *encdec.Enc, mv encdec.Value) error {
+	if mv.Kind() != encdec.KindNested || mv.Len() != len(m.Fields) {
+		return errMismatch
+	}
+	var p encdec.Presence
+	for i, f := range m.Fields {
+		if !isZero(f.Type, mv.Index(i)) {
+			p.Set(f.Number - 1)
+		}
+	}
+	enc.Presence(p)
+	for i, f := range m.Fields {
+		if p.Has(f.Number - 1) {
+			if err := encodeType(enc, f.Type, mv.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.Error()
+}
+
+//  isZero reports whether v is zero value of type t
+func isZero(t *Type, v encdec.Value) bool {
+	switch v.Kind() {
+	case encdec.KindUint:
+		return v.Uint() == 0
+	case encdec.KindInt:
+		return v.Int() == 0
+	case encdec.KindFloat:
+		return math.Float64bits(v.Float()) == 0
+	case encdec.KindBytes:
+		if t.Kind == Time {
+			return bytes.Equal(v.Bytes(), zeroTime)
+		}
+		return len(v.Bytes()) == 0
+	case encdec.KindString, encdec.KindList, encdec.KindMap:
+		return v.Len() == 0 && len(v.Bytes()) == 0
+	case encdec.KindNull:
+		return true
+	}
+	return false
+}
+
+var zeroTime, _ = time.Time{}.MarshalBinary()
+
+func encodeType(enc *encdec.Enc, t *Type, v encdec.Value) error {
+	k := v.Kind()
+	switch {
+	case t.Kind == Uint64 && k == encdec.KindUint:
+		enc.Uint64(v.Uint())
+	case t.Kind == Int64 && (k == encdec.KindInt || k == encdec.KindEnum):
+		enc.Int64(v.Int())
+	case t.Kind == Float64 && k == encdec.KindFloat:
+		enc.Float64(v.Float())
+	case t.Kind == Bool && k == encdec.KindUint && v.Uint() <= 1:
+		enc.Uint64(v.Uint())
+	case (t.Kind == Bytes || t.Kind == Time) && k == encdec.KindBytes:
+		enc.ByteSlice(nonNil(v.Bytes()))
+	case t.Kind == String && k == encdec.KindString:
+		enc.Str(v.Str())
+	case (t.Kind == MessageRef || t.Kind == Time || t.Kind == Bytes) && k == encdec.KindNull:
+		return errNilValue
+	case t.Kind == MessageRef && k == encdec.KindNested:
+		sub := encdec.NewEnc()
+		if err := t.Message.encode(sub, v); err != nil {
+			return err
+		}
+		enc.ByteSlice(sub.Bytes())
+	case t.Kind == List && k == encdec.KindList:
+		enc.List(v.Len())
+		for _, it := range v.Items() {
+			if err := encodeType(enc, t.Elem, it); err != nil {
+				return err
+			}
+		}
+	case t.Kind == Map && k == encdec.KindMap:
+		enc.Map(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeType(enc, t.Key, v.Key(i)); err != nil {
+				return err
+			}
+			if err := encodeType(enc, t.Elem, v.Elem(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return errMismatch
+	}
+	return nil
+}
+
+//  decode decodes message value nested in depth levels of messages, lists and maps
+func (m *Message) decode(dec *encdec.Dec, depth int) (encdec.Value, error) {
+	if m.Record {
+		return m.decodeRecord(dec, depth)
+	}
+	if m.Sparse {
+		return m.decodeSparse(dec, depth)
+	}
+	items := make([]encdec.Value, len(m.Fields))
+	for i, f := range m.Fields {
+		v, err := decodeType(dec, f.Type, depth)
+		if err != nil {
+			return encdec.Value{}, err
+		}
+		items[i] = v
+	}
+	return encdec.NestedValue(items...), dec.Error()
+}
+
+//  decodeRecord decodes record value, unknown fields are appended as a map of numbers to payloads
+func (m *Message) decodeRecord(dec *encdec.Dec, depth int) (encdec.Value, error) {
+	items := make([]encdec.Value, len(m.Fields), len(m.Fields)+1)
+	seen := make([]bool, len(m.Fields))
+	var unknown []encdec.Value
+	dec.BeginRecord()
+	for id, ok := dec.NextField(); ok; id, ok = dec.NextField() {
+		i := m.index(id)
+		if i < 0 {
+			data := append([]byte{}, dec.RawField().Data...)
+			unknown = append(unknown, encdec.UintValue(id), encdec.BytesValue(data))
+			continue
+		}
+		v, err := decodeType(dec, m.Fields[i].Type, depth)
+		if err != nil {
+			return v, err
+		}
+		items[i], seen[i] = v, true
+	}
+	if dec.Error() != nil {
+		return encdec.Value{}, dec.Error()
+	}
+	for i, f := range m.Fields {
+		switch {
+		case seen[i]:
+		case f.Required:
+			return encdec.Value{}, errors.New("schema: missing required field " + m.Name + "." + f.Name)
+		case f.Default.Kind() == encdec.KindBytes:
+			items[i] = encdec.BytesValue(append([]byte{}, f.Default.Bytes()...))
+		case f.Default.Kind() != encdec.KindInvalid:
+			items[i] = f.Default
+		default:
+			items[i] = zeroValue(f.Type)
+		}
+	}
+	if len(unknown) > 0 {
+		items = append(items, encdec.MapValue(unknown...))
+	}
+	return encdec.NestedValue(items...), nil
+}
+
+//  decodeSparse decodes presence bitmap and present fields, absent ones get zero values
+func (m *Message) decodeSparse(dec *encdec.Dec, depth int) (encdec.Value, error) {
+	p := dec.Presence()
+	items := make([]encdec.Value, len(m.Fields))
+	n := 0
+	for i, f := range m.Fields {
+		if !p.Has(f.Number - 1) {
+			items[i] = zeroValue(f.Type)
+			continue
+		}
+		v, err := decodeType(dec, f.Type, depth)
+		if err != nil {
+			return v, err
+		}
+		items[i] = v
+		n++
+	}
+	if dec.Error() != nil {
+		return encdec.Value{}, dec.Error()
+	}
+	if n != p.Len() {
+		// unknown fields can not be skipped
+		return encdec.Value{}, errMismatch
+	}
+	return encdec.NestedValue(items...), nil
+}
+
+//  zeroValue returns value of record or sparse field of type t missing in data
+func zeroValue(t *Type) encdec.Value {
+	switch t.Kind {
+	case Uint64, Bool:
+		return encdec.UintValue(0)
+	case Int64:
+		return encdec.IntValue(0)
+	case Float64:
+		return encdec.FloatValue(0)
+	case Bytes:
+		return encdec.BytesValue([]byte{})
+	case String:
+		return encdec.StringValue("")
+	case List:
+		return encdec.ListValue()
+	case Map:
+		return encdec.MapValue()
+	}
+	return encdec.NullValue()
+}
+
+//  decodeType decodes value of type t, depth counts enclosing messages, lists and maps
+func decodeType(dec *encdec.Dec, t *Type, depth int) (encdec.Value, error) {
+	var v encdec.Value
+	if (t.Kind == MessageRef || t.Kind == List || t.Kind == Map) && depth >= maxDepth {
+		return v, errDepth
+	}
+	switch t.Kind {
+	case Uint64:
+		v = encdec.UintValue(dec.Uint64())
+	case Int64:
+		v = encdec.IntValue(dec.Int64())
+	case Float64:
+		v = encdec.FloatValue(dec.Float64())
+	case Bool:
+		if v = encdec.UintValue(dec.Uint64()); v.Uint() > 1 {
+			return v, errMismatch
+		}
+	case Bytes, Time:
+		v = encdec.BytesValue(dec.ByteSliceCopy())
+	case String:
+		v = encdec.StringValue(dec.Str())
+	case MessageRef:
+		data := dec.ByteSlice()
+		if dec.Error() != nil {
+			return v, dec.Error()
+		}
+		sub := encdec.NewDec(data)
+		var err error
+		if v, err = t.Message.decode(sub, depth+1); err != nil {
+			return v, err
+		}
+		if sub.Len() != 0 {
+			return v, errMismatch
+		}
+	case List:
+		n := dec.List()
+		items := make([]encdec.Value, n)
+		for i := range items {
+			var err error
+			if items[i], err = decodeType(dec, t.Elem, depth+1); err != nil {
+				return v, err
+			}
+		}
+		v = encdec.ListValue(items...)
+	case Map:
+		n := dec.Map()
+		items := make([]encdec.Value, 2*n)
+		for i := 0; i < n; i++ {
+			var err error
+			if items[2*i], err = decodeType(dec, t.Key, depth+1); err != nil {
+				return v, err
+			}
+			if items[2*i+1], err = decodeType(dec, t.Elem, depth+1); err != nil {
+				return v, err
+			}
+		}
+		v = encdec.MapValue(items...)
+	default:
+		return v, errMismatch
+	}
+	return v, dec.Error()
+}
+
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
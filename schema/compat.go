@@ -0,0 +1,168 @@
+package schema
+
+import (
+	"fmt"
+)
+
+//  problems found by Check
+const (
+	ProblemLayoutChanged   = "layout-changed"   // message changed between positional and record layout
+	ProblemFieldAdded      = "field-added"      // positional message got a new field
+	ProblemFieldRemoved    = "field-removed"    // positional message lost a field
+	ProblemTypeChanged     = "type-changed"     // field has a type with different encoding
+	ProblemRenumbered      = "renumbered"       // field kept its name but changed its number
+	ProblemAddedRequired   = "added-required"   // record got a new required field
+	ProblemRemovedRequired = "removed-required" // record lost a required field
+	ProblemMadeRequired    = "made-required"    // optional record field became required
+	ProblemMadeOptional    = "made-optional"    // required record field became optional
+)
+
+//  Issue is an incompatibility between old and new version of a message
+type Issue struct {
+	Message  string `json:"message"`
+	Field    string `json:"field,omitempty"`
+	Number   int    `json:"number,omitempty"`
+	Problem  string `json:"problem"`
+	Detail   string `json:"detail"`
+	Backward bool   `json:"backward"` // new readers can not read old data
+	Forward  bool   `json:"forward"`  // old readers can not read new data
+}
+
+//  Report is a result of compatibility check
+type Report struct {
+	Backward bool    `json:"backward"` // new readers can read old data
+	Forward  bool    `json:"forward"`  // old readers can read new data
+	Issues   []Issue `json:"issues"`
+}
+
+//  Check reports whether messages of schema new can read data of old and vice versa
+//  messages are matched by name, messages missing in one of schemas are not checked
+func Check(old, new *Schema) *Report {
+	r := &Report{Backward: true, Forward: true, Issues: []Issue{}}
+	for _, om := range old.Messages {
+		if nm := new.Message(om.Name); nm != nil {
+			r.message(om, nm)
+		}
+	}
+	for _, is := range r.Issues {
+		r.Backward = r.Backward && !is.Backward
+		r.Forward = r.Forward && !is.Forward
+	}
+	return r
+}
+
+func (r *Report) add(m *Message, f *Field, problem, detail string, backward, forward bool) {
+	is := Issue{Message: m.Name, Problem: problem, Detail: detail, Backward: backward, Forward: forward}
+	if f != nil {
+		is.Field, is.Number = f.Name, f.Number
+	}
+	r.Issues = append(r.Issues, is)
+}
+
+func (r *Report) message(om, nm *Message) {
+	if om.Record != nm.Record || om.Sparse != nm.Sparse {
+		r.add(nm, nil, ProblemLayoutChanged, "record layout changed", true, true)
+		return
+	}
+	if om.Sparse {
+		r.sparse(om, nm)
+		return
+	}
+	if !om.Record {
+		// positional fields are matched by order
+		for i := 0; i < len(om.Fields) || i < len(nm.Fields); i++ {
+			switch {
+			case i >= len(nm.Fields):
+				r.add(om, om.Fields[i], ProblemFieldRemoved, "field removed from message", true, true)
+			case i >= len(om.Fields):
+				r.add(nm, nm.Fields[i], ProblemFieldAdded, "field added to message", true, true)
+			case !sameWire(om.Fields[i].Type, nm.Fields[i].Type):
+				r.add(nm, nm.Fields[i], ProblemTypeChanged, typeDetail(om.Fields[i], nm.Fields[i]), true, true)
+			}
+		}
+		return
+	}
+	for _, of := range om.Fields {
+		// a field keeping its name is renumbered even if its old number is reused, e.g. by swapping
+		rf := r.renumbered(of, nm)
+		nf := nm.number(of.Number)
+		if nf == nil {
+			if rf == nil && of.Required {
+				r.add(om, of, ProblemRemovedRequired, "required field removed", false, true)
+			}
+			continue
+		}
+		switch {
+		case !sameWire(of.Type, nf.Type):
+			r.add(nm, nf, ProblemTypeChanged, typeDetail(of, nf), true, true)
+		case !of.Required && nf.Required:
+			r.add(nm, nf, ProblemMadeRequired, "optional field became required", true, false)
+		case of.Required && !nf.Required:
+			r.add(nm, nf, ProblemMadeOptional, "required field became optional", false, true)
+		}
+	}
+	for _, nf := range nm.Fields {
+		if om.number(nf.Number) == nil && om.Field(nf.Name) == nil && nf.Required {
+			r.add(nm, nf, ProblemAddedRequired, "required field added", true, false)
+		}
+	}
+}
+
+//  sparse checks fields of sparse messages matched by number,
+//  old reader rejects bitmap with a bit of added field, new one rejects data of removed field as missing
+func (r *Report) sparse(om, nm *Message) {
+	for _, of := range om.Fields {
+		r.renumbered(of, nm)
+		nf := nm.number(of.Number)
+		switch {
+		case nf == nil:
+			r.add(om, of, ProblemFieldRemoved, "field removed from sparse message", true, false)
+		case !sameWire(of.Type, nf.Type):
+			r.add(nm, nf, ProblemTypeChanged, typeDetail(of, nf), true, true)
+		}
+	}
+	for _, nf := range nm.Fields {
+		if om.number(nf.Number) == nil {
+			r.add(nm, nf, ProblemFieldAdded, "field added to sparse message", false, true)
+		}
+	}
+}
+
+//  renumbered returns field of nm named as old field of and reports it if its number changed
+func (r *Report) renumbered(of *Field, nm *Message) *Field {
+	rf := nm.Field(of.Name)
+	if rf != nil && rf.Number != of.Number {
+		r.add(nm, rf, ProblemRenumbered, fmt.Sprintf("field number changed from %d to %d", of.Number, rf.Number), true, true)
+	}
+	return rf
+}
+
+//  number returns field of message with given number or nil
+func (m *Message) number(n int) *Field {
+	if i := m.index(uint64(n)); i >= 0 {
+		return m.Fields[i]
+	}
+	return nil
+}
+
+//  sameWire reports whether values of type a and b are encoded the same way
+//  referenced messages are compared by name, they are checked on their own
+func sameWire(a, b *Type) bool {
+	switch {
+	case (a.Kind == Bytes || a.Kind == String) && (b.Kind == Bytes || b.Kind == String):
+		return true
+	case a.Kind != b.Kind:
+		return false
+	case a.Kind == List:
+		return sameWire(a.Elem, b.Elem)
+	case a.Kind == Map:
+		return sameWire(a.Key, b.Key) && sameWire(a.Elem, b.Elem)
+	case a.Kind == MessageRef:
+		return a.Name == b.Name
+	}
+	return true
+}
+
+func typeDetail(of, nf *Field) string {
+	return fmt.Sprintf("type changed from %s to %s", of.Type, nf.Type)
+}
@@ -0,0 +1,103 @@
+package schema
+
+import (
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	old := MustParse(`
+record A {
+	1: required string id;
+	2: int64 count;
+	3: bytes data;
+	4: required uint64 gone;
+	5: list<B> items;
+	6: string moved;
+	7: bool flag;
+}
+message B {
+	1: string name;
+	2: int64 value;
+}
+message C {
+	1: string name;
+}
+`)
+	new := MustParse(`
+record A {
+	1: required string id;
+	2: bytes count;
+	3: string data;
+	5: list<B> items;
+	7: required bool flag;
+	8: float64 extra;
+	9: required uint64 key;
+	10: string moved;
+}
+message B {
+	1: string name;
+	2: int64 value;
+	3: int64 more;
+}
+record C {
+	1: string name;
+}
+`)
+	r := Check(old, old)
+	if !r.Backward || !r.Forward || len(r.Issues) != 0 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+
+	r = Check(old, new)
+	expected := []Issue{
+		{"A", "count", 2, ProblemTypeChanged, "type changed from int64 to bytes", true, true},
+		{"A", "gone", 4, ProblemRemovedRequired, "required field removed", false, true},
+		{"A", "moved", 10, ProblemRenumbered, "field number changed from 6 to 10", true, true},
+		{"A", "flag", 7, ProblemMadeRequired, "optional field became required", true, false},
+		{"A", "key", 9, ProblemAddedRequired, "required field added", true, false},
+		{"B", "more", 3, ProblemFieldAdded, "field added to message", true, true},
+		{"C", "", 0, ProblemLayoutChanged, "record layout changed", true, true},
+	}
+	if r.Backward || r.Forward || len(r.Issues) != len(expected) {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	for i, is := range r.Issues {
+		if is != expected[i] {
+			t.Errorf("expected: %+v and got: %+v", expected[i], is)
+		}
+	}
+
+	//only adding optional fields and dropping optional ones is compatible both ways
+	r = Check(MustParse("record A { 1: string a; 2: int64 b; }"), MustParse("record A { 1: bytes a; 3: time c; }"))
+	if !r.Backward || !r.Forward {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	r = Check(MustParse("record A { 1: required string a; }"), MustParse("record A { 1: string a; }"))
+	if !r.Backward || r.Forward {
+		t.Errorf("unexpected report: %+v", r)
+	}
+
+	//swapped numbers and a number reused by another field
+	r = Check(MustParse("record A { 1: string name; 2: string email; }"), MustParse("record A { 1: string email; 2: string name; }"))
+	if r.Backward || r.Forward || len(r.Issues) != 2 || r.Issues[0].Field != "name" || r.Issues[1].Field != "email" || r.Issues[0].Problem != ProblemRenumbered {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	r = Check(MustParse("record A { 1: string a; }"), MustParse("record A { 1: string b; 3: string a; }"))
+	if len(r.Issues) != 1 || r.Issues[0] != (Issue{"A", "a", 3, ProblemRenumbered, "field number changed from 1 to 3", true, true}) {
+		t.Errorf("unexpected report: %+v", r)
+	}
+
+	//sparse readers reject unknown bits, but fill in missing fields
+	r = Check(MustParse("sparse A { 1: string a; 2: int64 b; }"), MustParse("sparse A { 1: string a; 3: int64 c; }"))
+	if r.Backward || r.Forward || len(r.Issues) != 2 || r.Issues[0].Problem != ProblemFieldRemoved || r.Issues[1].Problem != ProblemFieldAdded {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	r = Check(MustParse("sparse A { 1: string a; 2: int64 b; }"), MustParse("sparse A { 1: string a; }"))
+	if r.Backward || !r.Forward {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	r = Check(MustParse("sparse A { 1: string a; }"), MustParse("message A { 1: string a; }"))
+	if len(r.Issues) != 1 || r.Issues[0].Problem != ProblemLayoutChanged {
+		t.Errorf("unexpected report: %+v", r)
+	}
+}
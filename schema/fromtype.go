@@ -0,0 +1,211 @@
+package schema
+
+import (
+	"encoding"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+//  FromType derives schema from struct type t (or pointer to it), t is the first message
+//  and struct types of its fields follow as messages named by their Go types.
+//  Field names are taken from encdec tag or from Go names in lower camel case,
+//  fields are numbered in struct order unless the tag gives a number: `encdec:"name,3,required"`.
+//  A struct with numbered or required fields or with a field keeping unknown fields is a record.
+//  A struct with blank field `_ struct{} `encdec:",sparse"`` is a sparse message, its tag numbers are bits of its bitmap.
+//  Types implementing encoding.BinaryMarshaler are opaque bytes.
+func FromType(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errNotStruct
+	}
+	b := &builder{s: &Schema{byName: make(map[string]*Message)}, types: make(map[reflect.Type]*Message)}
+	if _, err := b.message(t); err != nil {
+		return nil, err
+	}
+	if err := b.s.resolve(); err != nil {
+		return nil, err
+	}
+	return b.s, nil
+}
+
+//  builder collects messages of struct types
+type builder struct {
+	s     *Schema
+	types map[reflect.Type]*Message
+}
+
+func (b *builder) message(t reflect.Type) (*Message, error) {
+	if m := b.types[t]; m != nil {
+		return m, nil
+	}
+	if t.Name() == "" {
+		return nil, errors.New("schema: anonymous struct " + t.String())
+	}
+	if b.s.byName[t.Name()] != nil {
+		return nil, errors.New("schema: duplicate message " + t.Name())
+	}
+	m := &Message{Name: t.Name()}
+	b.types[t] = m
+	b.s.byName[m.Name] = m
+	b.s.Messages = append(b.s.Messages, m)
+	fields := make([]StructField, t.NumField())
+	for i := range fields {
+		sf := t.Field(i)
+		fields[i] = StructField{
+			Name:      sf.Name,
+			Exported:  sf.PkgPath == "",
+			Tag:       sf.Tag,
+			RawFields: sf.Type == rawFieldsType,
+			Type:      func() (*Type, error) { return b.typ(sf.Type) },
+		}
+	}
+	if err := BuildMessage(m, fields); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//  StructField describes a field of Go struct for BuildMessage
+type StructField struct {
+	Name      string                // Go name
+	Exported  bool                  // field is exported
+	Tag       reflect.StructTag     // field tag
+	RawFields bool                  // field is of type []encdec.RawField
+	Type      func() (*Type, error) // returns schema type of field, called only for encoded fields in struct order
+}
+
+//  BuildMessage fills message m from fields of a Go struct by the rules of FromType (naming, tags and their validation),
+//  so that code generators working with go/types derive the same messages as FromType does at run time
+func BuildMessage(m *Message, fields []StructField) error {
+	n, unknown := 0, false
+	for _, sf := range fields {
+		tag := strings.Split(sf.Tag.Get("encdec"), ",")
+		if sf.Name == "_" && len(tag) > 1 && tag[1] == "sparse" {
+			m.Sparse = true
+			continue
+		}
+		if !sf.Exported || tag[0] == "-" {
+			continue
+		}
+		if len(tag) > 1 && tag[1] == "unknown" {
+			if !sf.RawFields {
+				return errors.New("schema: unknown fields of " + m.Name + " must be []encdec.RawField")
+			}
+			m.Record, unknown = true, true
+			continue
+		}
+		f := &Field{Name: tag[0]}
+		if f.Name == "" {
+			f.Name = lowerCamel(sf.Name)
+		}
+		for _, opt := range tag[1:] {
+			switch x, err := strconv.Atoi(opt); {
+			case opt == "required":
+				f.Required, m.Record = true, true
+			case err == nil && x > 0:
+				n, m.Record = x-1, true
+			default:
+				return errors.New("schema: invalid tag option " + opt + " of " + m.Name + "." + sf.Name)
+			}
+		}
+		n++
+		f.Number = n
+		if m.index(uint64(n)) >= 0 || m.Field(f.Name) != nil {
+			return errors.New("schema: duplicate field " + f.Name + " in " + m.Name)
+		}
+		var err error
+		if f.Type, err = sf.Type(); err != nil {
+			return err
+		}
+		m.Fields = append(m.Fields, f)
+		sort.SliceStable(m.Fields, func(i, j int) bool { return m.Fields[i].Number < m.Fields[j].Number })
+	}
+	if m.Sparse {
+		if unknown {
+			return errors.New("schema: unknown fields in sparse " + m.Name)
+		}
+		for _, f := range m.Fields {
+			if f.Required {
+				return errors.New("schema: required field " + f.Name + " in sparse " + m.Name)
+			}
+		}
+		// field numbers are bits of presence bitmap
+		m.Record = false
+	}
+	return nil
+}
+
+func (b *builder) typ(t reflect.Type) (*Type, error) {
+	if t == timeType || (t.Kind() == reflect.Ptr && t.Elem() == timeType) {
+		return &Type{Kind: Time}, nil
+	}
+	if t.Implements(binaryMarshalerType) || reflect.PtrTo(t).Implements(binaryMarshalerType) {
+		return &Type{Kind: Bytes}, nil
+	}
+	switch k := t.Kind(); {
+	case k == reflect.Ptr && t.Elem().Kind() == reflect.Struct, k == reflect.Struct:
+		if k == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == valueType {
+			break
+		}
+		m, err := b.message(t)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Kind: MessageRef, Name: m.Name}, nil
+	case k == reflect.Bool:
+		return &Type{Kind: Bool}, nil
+	case k >= reflect.Int && k <= reflect.Int64:
+		return &Type{Kind: Int64}, nil
+	case k >= reflect.Uint && k <= reflect.Uintptr:
+		return &Type{Kind: Uint64}, nil
+	case k == reflect.Float32 || k == reflect.Float64:
+		return &Type{Kind: Float64}, nil
+	case k == reflect.String:
+		return &Type{Kind: String}, nil
+	case k == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return &Type{Kind: Bytes}, nil
+	case k == reflect.Slice || k == reflect.Array:
+		elem, err := b.typ(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Kind: List, Elem: elem}, nil
+	case k == reflect.Map:
+		key, err := b.typ(t.Key())
+		if err != nil {
+			return nil, err
+		}
+		elem, err := b.typ(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Kind: Map, Key: key, Elem: elem}, nil
+	}
+	return nil, errors.New("schema: unsupported type " + t.String())
+}
+
+//  lowerCamel lowers leading upper case letters of Go name, e.g. ID to id and URLPath to urlPath
+func lowerCamel(s string) string {
+	r := []rune(s)
+	for i := range r {
+		if !unicode.IsUpper(r[i]) || (i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mrkovec/encdec"
+)
+
+type testID [2]uint32
+
+func (id testID) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, id[0])
+	binary.BigEndian.PutUint32(b[4:], id[1])
+	return b, nil
+}
+
+func (id *testID) UnmarshalBinary(b []byte) error {
+	if len(b) != 8 {
+		return errors.New("invalid id")
+	}
+	id[0], id[1] = binary.BigEndian.Uint32(b), binary.BigEndian.Uint32(b[4:])
+	return nil
+}
+
+type testNode struct {
+	ID       testID
+	URLPath  string
+	Children []*testNode
+	Meta     map[string][]byte `encdec:"attributes"`
+	Owner    *testOwner
+	Skipped  int `encdec:"-"`
+	hidden   int
+}
+
+type testOwner struct {
+	Name    string `encdec:"name,1,required"`
+	Created *time.Time
+	Score   float32 `encdec:",5"`
+	Unknown []encdec.RawField `encdec:",unknown"`
+}
+
+func TestFromType(t *testing.T) {
+	s, err := FromType(reflect.TypeOf(&testNode{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `message testNode {
+	1: bytes id;
+	2: string urlPath;
+	3: list<testNode> children;
+	4: map<string, bytes> attributes;
+	5: testOwner owner;
+}
+
+record testOwner {
+	1: required string name;
+	2: time created;
+	5: float64 score;
+}
+`
+	if s.String() != expected {
+		t.Fatalf("expected: %v and got: %v", expected, s)
+	}
+
+	//derived schema encodes its types
+	ti := time.Unix(1e9, 0).UTC()
+	n := testNode{
+		ID:       testID{1, 2},
+		Children: []*testNode{{ID: testID{3, 4}, Children: []*testNode{}, Meta: map[string][]byte{}, Owner: &testOwner{Name: "x"}}},
+		Meta:     map[string][]byte{"a": {1}},
+		Owner:    &testOwner{Name: "John", Created: &ti, Score: 1.5},
+	}
+	data, err := s.Message("testNode").Marshal(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n2 testNode
+	if err = s.Message("testNode").Unmarshal(data, &n2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n, n2) {
+		t.Errorf("expected: %+v and got: %+v", n, n2)
+	}
+
+	for _, v := range []interface{}{
+		0,
+		struct{ A int }{},
+		struct {
+			A struct{ B int }
+		}{},
+		struct{ A chan int }{},
+		struct {
+			A int `encdec:",x"`
+		}{},
+		struct {
+			A int `encdec:",2"`
+			B int `encdec:",2"`
+		}{},
+		struct {
+			A []byte `encdec:",unknown"`
+		}{},
+	} {
+		if _, err = FromType(reflect.TypeOf(v)); err == nil {
+			t.Errorf("%T: expected: error got: nil", v)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package schema
+
+import (
+	"encoding"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrkovec/encdec"
+)
+
+var (
+	errNotStruct = errors.New("schema: expected struct or pointer to struct")
+	errNilValue  = errors.New("schema: nil message")
+
+	timeType      = reflect.TypeOf(time.Time{})
+	valueType     = reflect.TypeOf(encdec.Value{})
+	rawFieldsType = reflect.TypeOf([]encdec.RawField{})
+)
+
+//  value converts v to a message value
+func (m *Message) value(v interface{}) (encdec.Value, error) {
+	switch x := v.(type) {
+	case encdec.Value:
+		return x, nil
+	case *encdec.Value:
+		return *x, nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return encdec.Value{}, errNotStruct
+	}
+	return toValue(&Type{Kind: MessageRef, Name: m.Name, Message: m}, rv)
+}
+
+//  setStruct stores message value mv into v, a pointer to struct
+func (m *Message) setStruct(mv encdec.Value, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errNotStruct
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return errNotStruct
+	}
+	return fromValue(&Type{Kind: MessageRef, Name: m.Name, Message: m}, mv, rv)
+}
+
+//  structFields maps message fields to struct field indexes, -1 for fields missing in struct
+//  the extra last item is index of field keeping unknown record fields
+type fieldKey struct {
+	m *Message
+	t reflect.Type
+}
+
+var fieldMaps sync.Map
+
+func structFields(m *Message, t reflect.Type) []int {
+	if fm, ok := fieldMaps.Load(fieldKey{m, t}); ok {
+		return fm.([]int)
+	}
+	fm := make([]int, len(m.Fields)+1)
+	for i := range fm {
+		fm[i] = -1
+	}
+	for j := 0; j < t.NumField(); j++ {
+		sf := t.Field(j)
+		if sf.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(sf.Tag.Get("encdec"), ",")
+		if len(tag) > 1 && tag[1] == "unknown" {
+			if sf.Type == rawFieldsType {
+				fm[len(m.Fields)] = j
+			}
+			continue
+		}
+		if tag[0] == "-" {
+			continue
+		}
+		for i, f := range m.Fields {
+			if fm[i] < 0 && (tag[0] == f.Name || (tag[0] == "" && strings.EqualFold(sf.Name, f.Name))) {
+				fm[i] = j
+				break
+			}
+		}
+	}
+	fieldMaps.Store(fieldKey{m, t}, fm)
+	return fm
+}
+
+func toValue(t *Type, rv reflect.Value) (encdec.Value, error) {
+	if rv.Type() == valueType {
+		return rv.Interface().(encdec.Value), nil
+	}
+	if t.Kind == MessageRef || t.Kind == Time {
+		for rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return encdec.NullValue(), nil
+			}
+			rv = rv.Elem()
+		}
+	}
+	k := rv.Kind()
+	switch {
+	case t.Kind == Bytes && rv.Type().Implements(binaryMarshalerType):
+		if k == reflect.Ptr && rv.IsNil() {
+			return encdec.NullValue(), nil
+		}
+		b, err := rv.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		return encdec.BytesValue(nonNil(b)), err
+	case t.Kind == Bytes && reflect.PtrTo(rv.Type()).Implements(binaryMarshalerType):
+		p := reflect.New(rv.Type())
+		p.Elem().Set(rv)
+		b, err := p.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		return encdec.BytesValue(nonNil(b)), err
+	case t.Kind == Uint64 && k >= reflect.Uint && k <= reflect.Uintptr:
+		return encdec.UintValue(rv.Uint()), nil
+	case t.Kind == Int64 && k >= reflect.Int && k <= reflect.Int64:
+		return encdec.IntValue(rv.Int()), nil
+	case t.Kind == Float64 && (k == reflect.Float32 || k == reflect.Float64):
+		return encdec.FloatValue(rv.Float()), nil
+	case t.Kind == Bool && k == reflect.Bool:
+		return boolValue(rv.Bool()), nil
+	case t.Kind == Bytes && k == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return encdec.BytesValue(rv.Bytes()), nil
+	case t.Kind == String && k == reflect.String:
+		return encdec.StringValue(rv.String()), nil
+	case t.Kind == Time && rv.Type() == timeType:
+		b, err := rv.Interface().(time.Time).MarshalBinary()
+		return encdec.BytesValue(b), err
+	case t.Kind == MessageRef && k == reflect.Struct:
+		fm := structFields(t.Message, rv.Type())
+		n := len(t.Message.Fields)
+		items := make([]encdec.Value, n, n+1)
+		for i, j := range fm[:n] {
+			if j < 0 {
+				return encdec.Value{}, fieldError(t.Message, i, rv.Type())
+			}
+			var err error
+			if items[i], err = toValue(t.Message.Fields[i].Type, rv.Field(j)); err != nil {
+				return encdec.Value{}, err
+			}
+		}
+		if j := fm[n]; j >= 0 && t.Message.Record && rv.Field(j).Len() > 0 {
+			var kv []encdec.Value
+			for _, f := range rv.Field(j).Interface().([]encdec.RawField) {
+				kv = append(kv, encdec.UintValue(f.ID), encdec.BytesValue(nonNil(f.Data)))
+			}
+			items = append(items, encdec.MapValue(kv...))
+		}
+		return encdec.NestedValue(items...), nil
+	case t.Kind == List && (k == reflect.Slice || k == reflect.Array):
+		items := make([]encdec.Value, rv.Len())
+		for i := range items {
+			var err error
+			if items[i], err = toValue(t.Elem, rv.Index(i)); err != nil {
+				return encdec.Value{}, err
+			}
+		}
+		return encdec.ListValue(items...), nil
+	case t.Kind == Map && k == reflect.Map:
+		type pair struct{ k, v encdec.Value }
+		pairs := make([]pair, 0, rv.Len())
+		for it := rv.MapRange(); it.Next(); {
+			kv, err := toValue(t.Key, it.Key())
+			if err != nil {
+				return encdec.Value{}, err
+			}
+			vv, err := toValue(t.Elem, it.Value())
+			if err != nil {
+				return encdec.Value{}, err
+			}
+			pairs = append(pairs, pair{kv, vv})
+		}
+		// deterministic output
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].k.String() < pairs[j].k.String() })
+		items := make([]encdec.Value, 0, 2*len(pairs))
+		for _, p := range pairs {
+			items = append(items, p.k, p.v)
+		}
+		return encdec.MapValue(items...), nil
+	}
+	return encdec.Value{}, typeError(t, rv.Type())
+}
+
+func fromValue(t *Type, v encdec.Value, rv reflect.Value) error {
+	if rv.Type() == valueType {
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	}
+	if v.Kind() == encdec.KindNull && (t.Kind == MessageRef || t.Kind == Time || t.Kind == Bytes) {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	if rv.Kind() == reflect.Ptr && (t.Kind == MessageRef || t.Kind == Time) {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return fromValue(t, v, rv.Elem())
+	}
+	k := rv.Kind()
+	switch {
+	case t.Kind == Bytes && k == reflect.Ptr && rv.Type().Implements(binaryUnmarshalerType):
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return rv.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(v.Bytes())
+	case t.Kind == Bytes && k != reflect.Ptr && reflect.PtrTo(rv.Type()).Implements(binaryUnmarshalerType):
+		return rv.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(v.Bytes())
+	case t.Kind == Uint64 && k >= reflect.Uint && k <= reflect.Uintptr:
+		if rv.OverflowUint(v.Uint()) {
+			return typeError(t, rv.Type())
+		}
+		rv.SetUint(v.Uint())
+	case t.Kind == Int64 && k >= reflect.Int && k <= reflect.Int64:
+		if rv.OverflowInt(v.Int()) {
+			return typeError(t, rv.Type())
+		}
+		rv.SetInt(v.Int())
+	case t.Kind == Float64 && (k == reflect.Float32 || k == reflect.Float64):
+		rv.SetFloat(v.Float())
+	case t.Kind == Bool && k == reflect.Bool:
+		rv.SetBool(v.Uint() != 0)
+	case t.Kind == Bytes && k == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		rv.SetBytes(v.Bytes())
+	case t.Kind == String && k == reflect.String:
+		rv.SetString(v.Str())
+	case t.Kind == Time && rv.Type() == timeType:
+		var ti time.Time
+		if err := ti.UnmarshalBinary(v.Bytes()); err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(ti))
+	case t.Kind == MessageRef && k == reflect.Struct:
+		fm := structFields(t.Message, rv.Type())
+		n := len(t.Message.Fields)
+		for i, j := range fm[:n] {
+			if j < 0 {
+				continue
+			}
+			if err := fromValue(t.Message.Fields[i].Type, v.Index(i), rv.Field(j)); err != nil {
+				return err
+			}
+		}
+		if j := fm[n]; j >= 0 {
+			var raw []encdec.RawField
+			if v.Len() > n && v.Index(n).Kind() == encdec.KindMap {
+				u := v.Index(n)
+				for i := 0; i < u.Len(); i++ {
+					raw = append(raw, encdec.RawField{ID: u.Key(i).Uint(), Data: u.Elem(i).Bytes()})
+				}
+			}
+			rv.Field(j).Set(reflect.ValueOf(raw))
+		}
+	case t.Kind == List && k == reflect.Slice:
+		s := reflect.MakeSlice(rv.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := fromValue(t.Elem, v.Index(i), s.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(s)
+	case t.Kind == List && k == reflect.Array:
+		if v.Len() != rv.Len() {
+			return typeError(t, rv.Type())
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := fromValue(t.Elem, v.Index(i), rv.Index(i)); err != nil {
+				return err
+			}
+		}
+	case t.Kind == Map && k == reflect.Map:
+		mp := reflect.MakeMapWithSize(rv.Type(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			kv := reflect.New(rv.Type().Key()).Elem()
+			if err := fromValue(t.Key, v.Key(i), kv); err != nil {
+				return err
+			}
+			vv := reflect.New(rv.Type().Elem()).Elem()
+			if err := fromValue(t.Elem, v.Elem(i), vv); err != nil {
+				return err
+			}
+			mp.SetMapIndex(kv, vv)
+		}
+		rv.Set(mp)
+	default:
+		return typeError(t, rv.Type())
+	}
+	return nil
+}
+
+func boolValue(b bool) encdec.Value {
+	if b {
+		return encdec.UintValue(1)
+	}
+	return encdec.UintValue(0)
+}
+
+func typeError(t *Type, rt reflect.Type) error {
+	return errors.New("schema: can not use " + rt.String() + " as " + t.String())
+}
+
+func fieldError(m *Message, i int, rt reflect.Type) error {
+	return errors.New("schema: " + rt.String() + " has no field for " + m.Name + "." + m.Fields[i].Name)
+}
@@ -0,0 +1,499 @@
+/*
+  Package schema describes layouts of encdec streams in a small definition language
+  and encodes/decodes encdec.Value trees or Go structs according to them.
+
+  A schema is a list of messages:
+
+	// comment
+	message User {
+		1: string name;
+		2: int64 age;
+		3: time registered;
+		4: list<Address> addresses;
+		5: map<string, bytes> attributes;
+	}
+
+  Scalar types are uint64, int64, float64, bool, bytes, string and time,
+  composite types are list<T>, map<K, V> and names of other messages.
+  Fields are encoded in order of their numbers the same way as a hand written MarshalBinary would do it:
+  string as ByteSlice, time and messages as Marshaler, lists and maps as a count followed by items.
+
+  A record is a message that can evolve, it is encoded with encdec record API (Enc.BeginRecord),
+  every field carries its number and length:
+
+	record Account {
+		1: required string id;
+		2: int64 limit = 100;
+		3: time created = "2000-01-01T00:00:00Z";
+		4: Address address;
+	}
+
+  A reader skips fields it does not know, a field missing in data gets its default value
+  (zero value of its type, null for a message or time) and a missing required field is an error.
+  Unknown fields are kept in a trailing map of field numbers to raw payloads of decoded encdec.Value
+  or in a struct field of type []encdec.RawField tagged `encdec:",unknown"`,
+  so that they survive re-encoding by an older reader.
+
+  A sparse message starts with a presence bitmap (encdec.Presence) of its non-zero fields,
+  bit n-1 stands for field number n, only present fields follow.
+  Absent fields are decoded as zero values (null for a message or time):
+
+	sparse Settings {
+		1: uint64 port;
+		2: string proxy;
+		9: list<string> hosts;
+	}
+
+  FromType derives a schema from Go struct definitions, so that they can not drift apart.
+
+  Check compares two versions of a schema and reports changes that break reading of old data
+  by new readers or of new data by old readers, command encdecschema runs it from CI.
+*/
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mrkovec/encdec"
+)
+
+var (
+	errMismatch = errors.New("schema: value does not match schema")
+	errDepth    = errors.New("schema: data nested too deep")
+)
+
+//  maxDepth limits nesting of decoded messages, lists and maps
+const maxDepth = 1000
+
+//  TypeKind is a kind of field type
+type TypeKind int
+
+const (
+	Invalid TypeKind = iota
+	Uint64
+	Int64
+	Float64
+	Bool
+	Bytes
+	String
+	Time
+	List
+	Map
+	MessageRef
+)
+
+var scalarNames = map[string]TypeKind{
+	"uint64":  Uint64,
+	"int64":   Int64,
+	"float64": Float64,
+	"bool":    Bool,
+	"bytes":   Bytes,
+	"string":  String,
+	"time":    Time,
+}
+
+//  Type is a type of message field
+type Type struct {
+	Kind    TypeKind
+	Name    string   // message name of MessageRef
+	Elem    *Type    // item type of List, value type of Map
+	Key     *Type    // key type of Map
+	Message *Message // resolved message of MessageRef
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case List:
+		return "list<" + t.Elem.String() + ">"
+	case Map:
+		return "map<" + t.Key.String() + ", " + t.Elem.String() + ">"
+	case MessageRef:
+		return t.Name
+	}
+	for n, k := range scalarNames {
+		if k == t.Kind {
+			return n
+		}
+	}
+	return "invalid"
+}
+
+//  Field is a numbered field of message
+type Field struct {
+	Number   int
+	Name     string
+	Type     *Type
+	Required bool         // record field must be present in data
+	Default  encdec.Value // value of record field missing in data, KindInvalid for zero value
+}
+
+//  Message is a record layout
+type Message struct {
+	Name   string
+	Record bool     // fields are numbered on the wire
+	Sparse bool     // only non-zero fields follow presence bitmap
+	Fields []*Field // ordered by field number
+}
+
+//  Field returns field of message with given name or nil
+func (m *Message) Field(name string) *Field {
+	for _, f := range m.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+//  index returns index of field with given number or -1
+func (m *Message) index(n uint64) int {
+	i := sort.Search(len(m.Fields), func(i int) bool { return uint64(m.Fields[i].Number) >= n })
+	if i < len(m.Fields) && uint64(m.Fields[i].Number) == n {
+		return i
+	}
+	return -1
+}
+
+//  Schema is a set of messages
+type Schema struct {
+	Messages []*Message // in order of definition
+	byName   map[string]*Message
+}
+
+//  Message returns message with given name or nil
+func (s *Schema) Message(name string) *Message {
+	return s.byName[name]
+}
+
+//  String returns schema in the definition language
+func (s *Schema) String() string {
+	var sb strings.Builder
+	for i, m := range s.Messages {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if m.Record {
+			fmt.Fprintf(&sb, "record %s {\n", m.Name)
+		} else if m.Sparse {
+			fmt.Fprintf(&sb, "sparse %s {\n", m.Name)
+		} else {
+			fmt.Fprintf(&sb, "message %s {\n", m.Name)
+		}
+		for _, f := range m.Fields {
+			fmt.Fprintf(&sb, "\t%d: ", f.Number)
+			if f.Required {
+				sb.WriteString("required ")
+			}
+			fmt.Fprintf(&sb, "%s %s", f.Type, f.Name)
+			if f.Default.Kind() != encdec.KindInvalid {
+				sb.WriteString(" = " + literal(f.Type, f.Default))
+			}
+			sb.WriteString(";\n")
+		}
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+//  Parse parses schema definition
+func Parse(src string) (*Schema, error) {
+	p := &parser{src: src, line: 1}
+	s := &Schema{byName: make(map[string]*Message)}
+	for p.next(); p.tok != ""; {
+		m, err := p.message()
+		if err != nil {
+			return nil, err
+		}
+		if s.byName[m.Name] != nil {
+			return nil, p.errorf("duplicate message %s", m.Name)
+		}
+		s.Messages = append(s.Messages, m)
+		s.byName[m.Name] = m
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if err := s.resolve(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//  MustParse is like Parse but panics on error
+func MustParse(src string) *Schema {
+	s, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+//  resolve links message references to their messages
+func (s *Schema) resolve() error {
+	var res func(t *Type) error
+	res = func(t *Type) error {
+		switch t.Kind {
+		case MessageRef:
+			if t.Message = s.byName[t.Name]; t.Message == nil {
+				return fmt.Errorf("schema: undefined message %s", t.Name)
+			}
+		case List:
+			return res(t.Elem)
+		case Map:
+			if err := res(t.Key); err != nil {
+				return err
+			}
+			return res(t.Elem)
+		}
+		return nil
+	}
+	for _, m := range s.Messages {
+		for _, f := range m.Fields {
+			if err := res(f.Type); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//  parser is a recursive descent parser of schema definitions
+type parser struct {
+	src  string
+	pos  int
+	line int
+	tok  string
+	err  error
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.err == nil {
+		p.err = fmt.Errorf("schema: line %d: %s", p.line, fmt.Sprintf(format, args...))
+	}
+	return p.err
+}
+
+//  next reads next token, "" means end of input
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			goto token
+		}
+	}
+	p.tok = ""
+	return
+token:
+	start := p.pos
+	switch c := rune(p.src[p.pos]); {
+	case c == '_' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c):
+		for p.pos < len(p.src) {
+			c = rune(p.src[p.pos])
+			if c != '_' && c != '-' && c != '.' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+				break
+			}
+			p.pos++
+		}
+	case c == '"':
+		for p.pos++; p.pos < len(p.src) && p.src[p.pos] != '"'; p.pos++ {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+		}
+		p.pos++
+		if p.pos > len(p.src) {
+			p.pos = len(p.src)
+			p.errorf("unterminated string")
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+func (p *parser) expect(tok string) {
+	if p.tok != tok {
+		p.errorf("expected %q, found %q", tok, p.tok)
+	}
+	p.next()
+}
+
+func (p *parser) ident() string {
+	tok := p.tok
+	valid := tok != "" && (tok[0] == '_' || unicode.IsLetter(rune(tok[0])))
+	for _, c := range tok {
+		valid = valid && (c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c))
+	}
+	if !valid {
+		p.errorf("expected name, found %q", tok)
+	}
+	p.next()
+	return tok
+}
+
+func (p *parser) message() (*Message, error) {
+	m := &Message{Record: p.tok == "record", Sparse: p.tok == "sparse"}
+	if m.Record || m.Sparse {
+		p.next()
+	} else {
+		p.expect("message")
+	}
+	m.Name = p.ident()
+	p.expect("{")
+	numbers := make(map[int]bool)
+	for p.err == nil && p.tok != "}" && p.tok != "" {
+		f := p.field()
+		if p.err != nil {
+			break
+		}
+		if numbers[f.Number] {
+			p.errorf("duplicate field number %d in %s", f.Number, m.Name)
+		}
+		if m.Field(f.Name) != nil {
+			p.errorf("duplicate field %s in %s", f.Name, m.Name)
+		}
+		if !m.Record && (f.Required || f.Default.Kind() != encdec.KindInvalid) {
+			p.errorf("required and default fields are allowed only in records")
+		}
+		numbers[f.Number] = true
+		m.Fields = append(m.Fields, f)
+	}
+	p.expect("}")
+	sort.SliceStable(m.Fields, func(i, j int) bool { return m.Fields[i].Number < m.Fields[j].Number })
+	return m, p.err
+}
+
+func (p *parser) field() *Field {
+	f := &Field{}
+	n, err := strconv.Atoi(p.tok)
+	if err != nil || n <= 0 {
+		p.errorf("expected positive field number, found %q", p.tok)
+	}
+	f.Number = n
+	p.next()
+	p.expect(":")
+	if p.tok == "required" {
+		f.Required = true
+		p.next()
+	}
+	f.Type = p.typ()
+	f.Name = p.ident()
+	if p.tok == "=" {
+		p.next()
+		if f.Required {
+			p.errorf("required field %s can not have default", f.Name)
+		}
+		f.Default = p.literal(f.Type)
+	}
+	p.expect(";")
+	return f
+}
+
+//  literal parses default value of scalar type t
+func (p *parser) literal(t *Type) encdec.Value {
+	tok := p.tok
+	var v encdec.Value
+	var err error
+	switch t.Kind {
+	case Uint64:
+		var x uint64
+		x, err = strconv.ParseUint(tok, 10, 64)
+		v = encdec.UintValue(x)
+	case Int64:
+		var x int64
+		x, err = strconv.ParseInt(tok, 10, 64)
+		v = encdec.IntValue(x)
+	case Float64:
+		var x float64
+		x, err = strconv.ParseFloat(tok, 64)
+		v = encdec.FloatValue(x)
+	case Bool:
+		if tok != "true" && tok != "false" {
+			err = errMismatch
+		}
+		v = boolValue(tok == "true")
+	case Bytes, String, Time:
+		var x string
+		if x, err = strconv.Unquote(tok); err != nil {
+			break
+		}
+		switch t.Kind {
+		case Bytes:
+			v = encdec.BytesValue([]byte(x))
+		case String:
+			v = encdec.StringValue(x)
+		case Time:
+			var ti time.Time
+			if ti, err = time.Parse(time.RFC3339Nano, x); err == nil {
+				var b []byte
+				b, err = ti.MarshalBinary()
+				v = encdec.BytesValue(b)
+			}
+		}
+	default:
+		p.errorf("%s can not have default", t)
+		return v
+	}
+	if err != nil {
+		p.errorf("invalid %s default %s", t, tok)
+	}
+	p.next()
+	return v
+}
+
+//  literal formats default value v of scalar type t
+func literal(t *Type, v encdec.Value) string {
+	switch t.Kind {
+	case Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case Bool:
+		return strconv.FormatBool(v.Uint() != 0)
+	case Time:
+		var ti time.Time
+		ti.UnmarshalBinary(v.Bytes())
+		return strconv.Quote(ti.Format(time.RFC3339Nano))
+	}
+	return strconv.Quote(string(v.Bytes()))
+}
+
+func (p *parser) typ() *Type {
+	name := p.ident()
+	if k, ok := scalarNames[name]; ok {
+		return &Type{Kind: k}
+	}
+	switch name {
+	case "list":
+		p.expect("<")
+		t := &Type{Kind: List, Elem: p.typ()}
+		p.expect(">")
+		return t
+	case "map":
+		p.expect("<")
+		t := &Type{Kind: Map, Key: p.typ()}
+		p.expect(",")
+		t.Elem = p.typ()
+		p.expect(">")
+		return t
+	}
+	return &Type{Kind: MessageRef, Name: name}
+}
@@ -0,0 +1,390 @@
+package schema
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mrkovec/encdec"
+)
+
+const testSchema = `
+// users of a service
+message User {
+	1: string name;
+	2: int64 age;
+	3: time registered;
+	5: map<string, uint64> counters;
+	4: list<Address> addresses;
+}
+
+message Address {
+	1: string city;
+	2: bool primary;
+	3: float64 lat;
+	4: bytes raw;
+}
+`
+
+type testAddress struct {
+	City    string
+	Primary bool
+	Lat     float32
+	Raw     []byte
+}
+
+type testUser struct {
+	Name       string `encdec:"name"`
+	Age        int
+	Registered time.Time
+	Addresses  []*testAddress
+	Counters   map[string]uint32
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := s.Message("User")
+	if u == nil || len(u.Fields) != 5 || u.Fields[3].Name != "addresses" || u.Fields[3].Type.Elem.Message != s.Message("Address") {
+		t.Fatalf("unexpected schema: %v", s)
+	}
+	//printed schema parses to the same one
+	s2, err := Parse(s.String())
+	if err != nil || s2.String() != s.String() {
+		t.Errorf("expected: %v and got: %v, %v", s, s2, err)
+	}
+
+	for _, src := range []string{
+		"message A { 1: int64 a; 1: int64 b; }",
+		"message A { 1: int64 a; 2: int64 a; }",
+		"message A { 0: int64 a; }",
+		"message A { 1: B a; }",
+		"message A { 1: list<int64 a; }",
+		"message A { 1: int64 a-b; }",
+		"message A {} message A {}",
+		"message A { 1: int64 a }",
+		"msg A {}",
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("%q: expected: error got: nil", src)
+		}
+	}
+}
+
+func TestMarshalStruct(t *testing.T) {
+	s := MustParse(testSchema)
+	u := testUser{
+		Name:       "John",
+		Age:        30,
+		Registered: time.Unix(1e9, 0).UTC(),
+		Addresses:  []*testAddress{{"Bratislava", true, 48.1, []byte{1}}, {"Wien", false, 0, []byte{}}},
+		Counters:   map[string]uint32{"b": 2, "a": 1},
+	}
+	data, err := s.Message("User").Marshal(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//layout equals hand written encoding
+	enc := encdec.NewEnc()
+	enc.ByteSlice([]byte("John"))
+	enc.Int64(30)
+	enc.Marshaler(u.Registered)
+	enc.Uint64(2)
+	for _, a := range u.Addresses {
+		sub := encdec.NewEnc()
+		sub.ByteSlice([]byte(a.City))
+		if a.Primary {
+			sub.Uint64(1)
+		} else {
+			sub.Uint64(0)
+		}
+		sub.Float64(float64(a.Lat))
+		sub.ByteSlice(a.Raw)
+		enc.ByteSlice(sub.Bytes())
+	}
+	enc.Uint64(2)
+	enc.ByteSlice([]byte("a"))
+	enc.Uint64(1)
+	enc.ByteSlice([]byte("b"))
+	enc.Uint64(2)
+	if !bytes.Equal(data, enc.Bytes()) {
+		t.Fatalf("expected: %v and got: %v", enc.Bytes(), data)
+	}
+
+	var u2 testUser
+	if err = s.Message("User").Unmarshal(data, &u2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(u, u2) {
+		t.Errorf("expected: %v and got: %v", u, u2)
+	}
+
+	//value tree
+	var v encdec.Value
+	if err = s.Message("User").Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Index(0).Str() != "John" || v.Index(3).Index(1).Index(0).Str() != "Wien" {
+		t.Errorf("unexpected value: %v", v)
+	}
+	v.Items()[1] = encdec.IntValue(31)
+	data2, err := s.Message("User").Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Message("User").Unmarshal(data2, &u2); err != nil || u2.Age != 31 {
+		t.Errorf("expected: 31 and got: %v, %v", u2.Age, err)
+	}
+
+	//mismatches
+	if _, err = s.Message("User").Marshal(struct{ Name string }{"x"}); err == nil {
+		t.Error("expected: error got: nil")
+	}
+	if _, err = s.Message("Address").Marshal(struct {
+		City, Primary, Lat, Raw string
+	}{}); err == nil {
+		t.Error("expected: error got: nil")
+	}
+	v.Items()[1] = encdec.StringValue("x")
+	if _, err = s.Message("User").Marshal(v); err != errMismatch {
+		t.Errorf("expected: %v and got: %v", errMismatch, err)
+	}
+	if err = s.Message("User").Unmarshal(data[:len(data)-2], &u2); err == nil {
+		t.Error("expected: error got: nil")
+	}
+	if err = s.Message("User").Unmarshal(append(data, 0), &u2); err != errMismatch {
+		t.Errorf("expected: %v and got: %v", errMismatch, err)
+	}
+}
+
+func TestDeep(t *testing.T) {
+	s, err := Parse("message A { 1: list<A> c; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := encdec.NewEnc()
+	nest := func(levels int) []byte {
+		enc.Reset()
+		enc.List(0)
+		for i := 0; i < levels; i++ {
+			data := enc.Bytes()
+			enc.Reset()
+			enc.List(1)
+			enc.ByteSlice(data)
+		}
+		return enc.Bytes()
+	}
+	var v encdec.Value
+	if err = s.Message("A").Unmarshal(nest(10), &v); err != nil || v.Index(0).Index(0).Index(0).Len() != 1 {
+		t.Errorf("expected: %v and got: %v", nil, err)
+	}
+	if err = s.Message("A").Unmarshal(nest(maxDepth), &v); err != errDepth {
+		t.Errorf("expected: %v and got: %v", errDepth, err)
+	}
+}
+
+const testRecordsV1 = `
+record Account {
+	1: required string id;
+	2: int64 limit = -100;
+	4: Address address;
+	5: bool active = true;
+	6: time created = "2000-01-01T00:00:00Z";
+	7: bytes tag = "a\"b";
+	8: float64 rate = 0.5;
+}
+
+message Address {
+	1: string city;
+	2: bool primary;
+	3: float64 lat;
+	4: bytes raw;
+}
+`
+
+const testRecordsV2 = `
+record Account {
+	1: required string id;
+	3: list<string> owners;
+	4: Address address;
+	6: time created;
+	9: uint64 version;
+}
+
+message Address {
+	1: string city;
+	2: bool primary;
+	3: float64 lat;
+	4: bytes raw;
+}
+`
+
+type testAccountV1 struct {
+	ID      string
+	Limit   int64
+	Address *testAddress
+	Active  bool
+	Created time.Time
+	Tag     []byte
+	Rate    float64
+	Unknown []encdec.RawField `encdec:",unknown"`
+}
+
+type testAccountV2 struct {
+	ID      string
+	Owners  []string
+	Address *testAddress
+	Created time.Time
+	Version uint64
+}
+
+func TestRecord(t *testing.T) {
+	s1, s2 := MustParse(testRecordsV1), MustParse(testRecordsV2)
+	if s, err := Parse(s1.String()); err != nil || s.String() != s1.String() {
+		t.Errorf("expected: %v and got: %v, %v", s1, s, err)
+	}
+	for _, src := range []string{
+		"message A { 1: required int64 a; }",
+		"record A { 1: required int64 a = 1; }",
+		"record A { 1: int64 a = x; }",
+		"record A { 1: bool a = 1; }",
+		"record A { 1: time a = \"yesterday\"; }",
+		"record A { 1: list<int64> a = 1; }",
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("%q: expected: error got: nil", src)
+		}
+	}
+
+	//new writer, old reader
+	a2 := testAccountV2{ID: "x1", Owners: []string{"John"}, Created: time.Unix(1e9, 0).UTC(), Version: 2}
+	data, err := s2.Message("Account").Marshal(&a2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a1 testAccountV1
+	if err = s1.Message("Account").Unmarshal(data, &a1); err != nil {
+		t.Fatal(err)
+	}
+	if a1.ID != "x1" || a1.Limit != -100 || a1.Address != nil || !a1.Active || !a1.Created.Equal(a2.Created) ||
+		string(a1.Tag) != "a\"b" || a1.Rate != 0.5 || len(a1.Unknown) != 2 || a1.Unknown[1].ID != 9 {
+		t.Errorf("unexpected record: %+v", a1)
+	}
+
+	//old reader re-encodes, new reader gets all of its fields back
+	a1.Limit = 5
+	a1.Address = &testAddress{City: "Wien", Raw: []byte{}}
+	data1, err := s1.Message("Account").Marshal(&a1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b2 testAccountV2
+	if err = s2.Message("Account").Unmarshal(data1, &b2); err != nil {
+		t.Fatal(err)
+	}
+	a2.Address = a1.Address
+	if !reflect.DeepEqual(a2, b2) {
+		t.Errorf("expected: %v and got: %v", a2, b2)
+	}
+
+	//unknown fields survive value trees byte for byte
+	var v encdec.Value
+	if err = s1.Message("Account").Unmarshal(data1, &v); err != nil {
+		t.Fatal(err)
+	}
+	if data2, err := s1.Message("Account").Marshal(v); err != nil || !bytes.Equal(data2, data1) {
+		t.Errorf("expected: %v and got: %v, %v", data1, data2, err)
+	}
+
+	//new reader, old writer with defaults of new fields
+	data1, err = s1.Message("Account").Marshal(&testAccountV1{ID: "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s2.Message("Account").Unmarshal(data1, &b2); err != nil || b2.Version != 0 || len(b2.Owners) != 0 || !b2.Created.IsZero() {
+		t.Errorf("unexpected record: %+v, %v", b2, err)
+	}
+
+	//missing required field
+	enc := encdec.NewEnc()
+	enc.BeginRecord()
+	enc.Field(9)
+	enc.Uint64(1)
+	enc.EndRecord()
+	if err = s2.Message("Account").Unmarshal(enc.Bytes(), &b2); err == nil {
+		t.Error("expected: error got: nil")
+	}
+}
+
+const testSparse = `
+sparse Settings {
+	1: uint64 port;
+	2: string proxy;
+	3: Address address;
+	4: time updated;
+	20: list<string> hosts;
+	21: float64 ratio;
+}
+`
+
+type testSettings struct {
+	_       struct{} `encdec:",sparse"`
+	Port    uint16
+	Proxy   string
+	Address *testAddress
+	Updated time.Time
+	Hosts   []string `encdec:",20"`
+	Ratio   float64
+}
+
+func TestSparse(t *testing.T) {
+	s := MustParse(testSparse + testSchema)
+	if s2, err := Parse(s.String()); err != nil || s2.String() != s.String() {
+		t.Errorf("expected: %v and got: %v, %v", s, s2, err)
+	}
+	if s2, err := FromType(reflect.TypeOf(testSettings{})); err != nil || !s2.Messages[0].Sparse || s2.Messages[0].Record ||
+		s2.Messages[0].Field("hosts").Number != 20 || s2.Messages[0].Field("ratio").Number != 21 {
+		t.Errorf("unexpected schema: %v, %v", s2, err)
+	}
+	if _, err := Parse("sparse A { 1: required int64 a; }"); err == nil {
+		t.Error("expected: error got: nil")
+	}
+	m := s.Message("Settings")
+
+	//zero values are left out
+	data, err := m.Marshal(&testSettings{})
+	if err != nil || !bytes.Equal(data, []byte{1, 0}) {
+		t.Errorf("expected: %v and got: %v, %v", []byte{1, 0}, data, err)
+	}
+	in := testSettings{Proxy: "p", Hosts: []string{"a", "b"}}
+	data, err = m.Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//bitmap of 3 bytes, proxy and hosts
+	if len(data) != 5+3+8 {
+		t.Errorf("expected: %v and got: %v", 16, len(data))
+	}
+	out := testSettings{Port: 1, Address: &testAddress{}, Updated: time.Now()}
+	if err = m.Unmarshal(data, &out); err != nil || !reflect.DeepEqual(in, out) {
+		t.Errorf("expected: %v and got: %v, %v", in, out, err)
+	}
+
+	//value trees have all fields
+	var v encdec.Value
+	if err = m.Unmarshal(data, &v); err != nil || v.Len() != len(m.Fields) || v.Index(2).Kind() != encdec.KindNull {
+		t.Errorf("unexpected value: %v, %v", v, err)
+	}
+	if data2, err := m.Marshal(v); err != nil || !bytes.Equal(data2, data) {
+		t.Errorf("expected: %v and got: %v, %v", data, data2, err)
+	}
+
+	//unknown field can not be skipped
+	if err = MustParse("sparse Settings { 1: uint64 port; }").Message("Settings").Unmarshal(data, &v); err == nil {
+		t.Error("expected: error got: nil")
+	}
+}
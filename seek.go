@@ -0,0 +1,130 @@
+package encdec
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errSeek = errors.New("encdec: invalid seek position")
+
+//  Mark is a decoding position token returned by Dec.Mark
+type Mark struct {
+	i      int
+	window []byte
+	next   int
+	skip   int
+	base   int
+	fields []decField
+	shared int
+	dict   int
+}
+
+//  Mark returns actual decoding position, decoder can return to it with Rewind
+func (d *Dec) Mark() Mark {
+	m := Mark{i: d.i, shared: len(d.shared), dict: d.dictLen()}
+	if len(d.fields) > 0 {
+		m.fields = append([]decField{}, d.fields...)
+	}
+	if d.orig != nil {
+		m.window = d.decbuf
+		m.next = len(d.orig) - len(d.chunks)
+		m.skip = d.skip
+		m.base = d.base
+	}
+	return m
+}
+
+//  Rewind restores decoding position saved by Mark and clears decoding error
+//  data read by ReadFrom after Mark stays in decoder
+func (d *Dec) Rewind(m Mark) {
+	d.err = nil
+	d.i = m.i
+	d.fields = append(d.fields[:0], m.fields...)
+	if m.shared <= len(d.shared) {
+		// objects decoded after mark are decoded again
+		d.shared = d.shared[:m.shared]
+	}
+	if d.dict != nil && m.dict <= d.dictLen() {
+		d.dict.entries = d.dict.entries[:m.dict]
+	}
+	if d.orig == nil {
+		return
+	}
+	d.decbuf = m.window
+	d.chunks = d.orig[m.next:]
+	d.skip = m.skip
+	d.base = m.base
+	d.rest = -d.skip
+	for _, c := range d.chunks {
+		d.rest += len(c)
+	}
+}
+
+//  Seek implements io.Seeker, it moves decoding position and clears decoding error
+//  new position has to be the end of data or a start of well formed entity, otherwise position is kept and error returned
+//  as encoded data are untyped, an offset inside ByteSlice payload resembling an entity can not be recognized
+//  in dictionary mode table of the new position is unknown, so Seek always fails, Mark and Rewind work
+func (d *Dec) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += int64(d.Pos())
+	case io.SeekEnd:
+		offset += int64(d.Pos() + d.Len())
+	default:
+		return int64(d.Pos()), errSeek
+	}
+	if offset < 0 || d.dict != nil || offset > int64(d.Pos()+d.Len()) {
+		return int64(d.Pos()), errSeek
+	}
+	m, err := d.Mark(), d.err
+	d.err = nil
+	if d.orig == nil {
+		d.i = int(offset)
+	} else {
+		d.seekChunks(int(offset))
+	}
+	if !d.atEntity() {
+		d.Rewind(m)
+		d.err = err
+		return int64(d.Pos()), errSeek
+	}
+	return offset, nil
+}
+
+//  seekChunks moves decoding position of chunked decoder to pos
+func (d *Dec) seekChunks(pos int) {
+	d.resetChunks()
+	for len(d.chunks) > 0 && pos >= d.base+len(d.decbuf) {
+		d.base += len(d.decbuf)
+		d.decbuf = d.chunks[0]
+		d.rest -= len(d.decbuf)
+		d.chunks = d.chunks[1:]
+	}
+	d.i = pos - d.base
+}
+
+//  atEntity reports whether an encoded entity, end marker or end of data is at decoding position
+func (d *Dec) atEntity() bool {
+	if d.Len() == 0 {
+		return true
+	}
+	if d.tagged {
+		return d.Kind() != KindInvalid
+	}
+	d.need(1)
+	lng := int(d.decbuf[d.i])
+	if lng == endMarker {
+		return true
+	}
+	if lng > binary.MaxVarintLen64 {
+		return false
+	}
+	d.need(1 + lng)
+	if len(d.decbuf)-d.i < 1+lng {
+		return false
+	}
+	_, n := binary.Uvarint(d.decbuf[d.i+1 : d.i+1+lng])
+	return n == lng
+}
@@ -0,0 +1,59 @@
+package encdec
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestDecMarkRewindSeek(t *testing.T) {
+	enc := NewEnc()
+	enc.Uint64(300)
+	enc.ByteSlice([]byte{200, 201, 202})
+	enc.Int64(-5)
+	data := enc.Bytes()
+
+	decs := []*Dec{NewDec(data), NewDecChunks([][]byte{data[:2], data[2:4], data[4:5], data[5:]})}
+	for k, dec := range decs {
+		//speculative decoding
+		m := dec.Mark()
+		dec.Float64()
+		dec.Unmarshaler(&testType{})
+		if dec.Error() == nil {
+			t.Fatalf("%v: expected: error got: nil", k)
+		}
+		dec.Rewind(m)
+		if dec.Error() != nil || dec.Pos() != 0 || dec.Uint64() != 300 {
+			t.Fatalf("%v: rewind failed: %v", k, dec.Error())
+		}
+		m = dec.Mark()
+		if !bytes.Equal(dec.ByteSlice(), []byte{200, 201, 202}) || dec.Int64() != -5 {
+			t.Fatalf("%v: decoding failed", k)
+		}
+		dec.Rewind(m)
+		if !bytes.Equal(dec.ByteSlice(), []byte{200, 201, 202}) || dec.Error() != nil {
+			t.Fatalf("%v: decoding after rewind failed", k)
+		}
+
+		//seek
+		if pos, err := dec.Seek(0, io.SeekStart); err != nil || pos != 0 || dec.Uint64() != 300 {
+			t.Errorf("%v: expected: 0 and got: %v, %v", k, pos, err)
+		}
+		if pos, err := dec.Seek(-2, io.SeekEnd); err != nil || pos != int64(len(data)-2) || dec.Int64() != -5 {
+			t.Errorf("%v: expected: %v and got: %v, %v", k, len(data)-2, pos, err)
+		}
+		if pos, err := dec.Seek(0, io.SeekEnd); err != nil || pos != int64(len(data)) || dec.Len() != 0 {
+			t.Errorf("%v: expected: %v and got: %v, %v", k, len(data), pos, err)
+		}
+		//inside of an entity or out of data
+		dec.Seek(3, io.SeekStart)
+		for _, off := range []int64{1, 5, -1, int64(len(data) + 1)} {
+			if pos, err := dec.Seek(off, io.SeekStart); err != errSeek || pos != 3 {
+				t.Errorf("%v: offset %v: expected: %v and got: %v, %v", k, off, errSeek, pos, err)
+			}
+		}
+		if !bytes.Equal(dec.ByteSlice(), []byte{200, 201, 202}) || dec.Error() != nil {
+			t.Errorf("%v: position changed by invalid seek", k)
+		}
+	}
+}
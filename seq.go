@@ -0,0 +1,85 @@
+//go:build go1.23
+
+package encdec
+
+import (
+	"encoding"
+	"iter"
+)
+
+//  EncodeSeq encodes records produced by seq as a list of unknown length
+//  the list is enclosed in end markers, so it can be written before the number of records is known
+func EncodeSeq[T encoding.BinaryMarshaler](enc *Enc, seq iter.Seq[T]) {
+	enc.end()
+	for v := range seq {
+		if enc.err != nil {
+			return
+		}
+		enc.Marshaler(v)
+	}
+	enc.end()
+}
+
+//  EncodeSeqN encodes n records produced by seq as a count prefixed list
+//  seq producing a different number of records is an encoding error
+func EncodeSeqN[T encoding.BinaryMarshaler](enc *Enc, n int, seq iter.Seq[T]) {
+	enc.Uint64(uint64(n))
+	i := 0
+	for v := range seq {
+		if enc.err != nil {
+			return
+		}
+		if i == n {
+			enc.err = errEncode
+			return
+		}
+		enc.Marshaler(v)
+		i++
+	}
+	if i != n && enc.err == nil {
+		enc.err = errEncode
+	}
+}
+
+//  DecodeSeq lazily decodes a list of records written by EncodeSeq, EncodeSeqN
+//  or by hand as a count (Uint64) followed by records (Marshaler)
+//  iteration stops after the first error, which is also kept as the sticky decoding error
+func DecodeSeq[T any, PT interface {
+	*T
+	encoding.BinaryUnmarshaler
+}](dec *Dec) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if dec.end() {
+			for !dec.end() {
+				if !decodeSeqItem[T, PT](dec, yield) {
+					return
+				}
+			}
+		} else {
+			n := dec.Uint64()
+			for i := uint64(0); i < n && dec.err == nil; i++ {
+				if !decodeSeqItem[T, PT](dec, yield) {
+					return
+				}
+			}
+		}
+		if dec.err != nil {
+			yield(zero, dec.err)
+		}
+	}
+}
+
+//  decodeSeqItem decodes and yields one record of a list, false means stop
+func decodeSeqItem[T any, PT interface {
+	*T
+	encoding.BinaryUnmarshaler
+}](dec *Dec, yield func(T, error) bool) bool {
+	var v T
+	dec.Unmarshaler(PT(&v))
+	if dec.err != nil {
+		yield(v, dec.err)
+		return false
+	}
+	return yield(v, nil)
+}
@@ -0,0 +1,86 @@
+//go:build go1.23
+
+package encdec
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEncDecSeq(t *testing.T) {
+	items := make([]*testType, 50)
+	for i := range items {
+		v := newTestType()
+		v.A = i
+		items[i] = &v
+	}
+
+	enc := NewEnc()
+	EncodeSeq(enc, slices.Values(items))
+	EncodeSeqN(enc, len(items), slices.Values(items))
+	//manual count prefixed list
+	enc.Uint64(uint64(len(items)))
+	for _, v := range items {
+		enc.Marshaler(v)
+	}
+	EncodeSeq(enc, slices.Values([]*testType{}))
+	if enc.Error() != nil {
+		t.Fatal(enc.Error())
+	}
+
+	dec := NewDec(enc.Bytes())
+	for rep := 0; rep < 3; rep++ {
+		i := 0
+		for v, err := range DecodeSeq[testType](dec) {
+			if err != nil || v.A != i {
+				t.Fatalf("expected: %v and got: %v, %v", i, v.A, err)
+			}
+			i++
+		}
+		if i != len(items) {
+			t.Errorf("expected: %v records and got: %v", len(items), i)
+		}
+	}
+	for range DecodeSeq[testType](dec) {
+		t.Error("expected: empty list")
+	}
+	if dec.Error() != nil || dec.Len() != 0 {
+		t.Errorf("expected: end of data and got: %v, %v", dec.Len(), dec.Error())
+	}
+
+	//early break leaves decoder after the last consumed record
+	dec.Reset()
+	for v := range DecodeSeq[testType](dec) {
+		if v.A == 9 {
+			break
+		}
+	}
+	var v testType
+	dec.Unmarshaler(&v)
+	if v.A != 10 {
+		t.Errorf("expected: 10 and got: %v", v.A)
+	}
+
+	//truncated list
+	b := enc.Bytes()
+	dec = NewDec(b[:len(b)/8])
+	var last error
+	for _, err := range DecodeSeq[testType](dec) {
+		last = err
+	}
+	if last == nil || last != dec.Error() {
+		t.Errorf("expected: error and got: %v", last)
+	}
+
+	//count mismatch
+	enc.Reset()
+	EncodeSeqN(enc, 3, slices.Values(items))
+	if enc.Error() != errEncode {
+		t.Errorf("expected: %v and got: %v", errEncode, enc.Error())
+	}
+	enc.Reset()
+	EncodeSeqN(enc, 100, slices.Values(items))
+	if enc.Error() != errEncode {
+		t.Errorf("expected: %v and got: %v", errEncode, enc.Error())
+	}
+}
@@ -0,0 +1,79 @@
+package encdec
+
+import (
+	"reflect"
+)
+
+//  Shared references keep identity of pointers in encoded graphs: an object gets an id when it is encoded first time
+//  and later occurrences are encoded as the id only, so shared objects are not duplicated and cycles terminate.
+//  Reference is a Uint64: 0 is nil, the next unused id is followed by object data, a used id refers back.
+
+//  RefMarshaler is implemented by pointer types encoding their data directly into encoder,
+//  references nested in the data are tracked by the same encoder
+type RefMarshaler interface {
+	EncodeTo(e *Enc)
+}
+
+//  RefUnmarshaler is implemented by pointer types decoding data encoded by their RefMarshaler
+type RefUnmarshaler interface {
+	DecodeFrom(d *Dec)
+}
+
+//  Ref encodes reference to object x, a pointer, the object data are encoded by x.EncodeTo when x is encoded first time
+//  ids of objects are valid until Reset
+func (e *Enc) Ref(x RefMarshaler) {
+	if e.err != nil {
+		return
+	}
+	v := reflect.ValueOf(x)
+	if x == nil || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		e.Uint64(0)
+		return
+	}
+	if v.Kind() != reflect.Ptr {
+		e.err = errEncode
+		return
+	}
+	if id, ok := e.sharedIDs[x]; ok {
+		e.Uint64(id)
+		return
+	}
+	if e.sharedIDs == nil {
+		e.sharedIDs = make(map[interface{}]uint64)
+	}
+	e.shared = append(e.shared, x)
+	id := uint64(len(e.shared))
+	e.sharedIDs[x] = id
+	e.Uint64(id)
+	x.EncodeTo(e)
+}
+
+//  DecodeRef decodes reference encoded by Enc.Ref, all references to one object return the same pointer
+//  an object is known to decoder before its DecodeFrom is called, so references in cycles resolve to it
+func DecodeRef[T any, PT interface {
+	*T
+	RefUnmarshaler
+}](d *Dec) *T {
+	id := d.Uint64()
+	if d.err != nil || id == 0 {
+		return nil
+	}
+	switch {
+	case id <= uint64(len(d.shared)):
+		x, ok := d.shared[id-1].(*T)
+		if !ok {
+			d.err = errDecode
+		}
+		return x
+	case id == uint64(len(d.shared))+1:
+		x := new(T)
+		d.shared = append(d.shared, x)
+		PT(x).DecodeFrom(d)
+		if d.err != nil {
+			return nil
+		}
+		return x
+	}
+	d.err = errDecode
+	return nil
+}
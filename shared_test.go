@@ -0,0 +1,96 @@
+package encdec
+
+import (
+	"testing"
+)
+
+type graphNode struct {
+	name  string
+	edges []*graphNode
+}
+
+func (n *graphNode) EncodeTo(e *Enc) {
+	e.Str(n.name)
+	e.Uint64(uint64(len(n.edges)))
+	for _, m := range n.edges {
+		e.Ref(m)
+	}
+}
+
+func (n *graphNode) DecodeFrom(d *Dec) {
+	n.name = d.Str()
+	l := d.Uint64()
+	if l > uint64(d.Len()) {
+		d.err = errDecode
+		return
+	}
+	n.edges = make([]*graphNode, l)
+	for i := range n.edges {
+		n.edges[i] = DecodeRef[graphNode](d)
+	}
+}
+
+type otherNode struct{ graphNode }
+
+func TestRef(t *testing.T) {
+	//a -> b -> c -> a, a -> c, b -> nil
+	a, b, c := &graphNode{name: "a"}, &graphNode{name: "b"}, &graphNode{name: "c"}
+	a.edges = []*graphNode{b, c}
+	b.edges = []*graphNode{c, nil}
+	c.edges = []*graphNode{a}
+	for _, tagged := range []bool{false, true} {
+		enc := NewEnc()
+		enc.SetTagged(tagged)
+		enc.Ref(a)
+		enc.Ref(c)
+		if enc.Error() != nil {
+			t.Fatal(enc.Error())
+		}
+		dec := NewDec(enc.Bytes())
+		dec.SetTagged(tagged)
+		a2 := DecodeRef[graphNode](dec)
+		c2 := DecodeRef[graphNode](dec)
+		if dec.Error() != nil || dec.Len() != 0 {
+			t.Fatal(dec.Error())
+		}
+		b2 := a2.edges[0]
+		if a2.name != "a" || b2.name != "b" || c2.name != "c" || a2.edges[1] != c2 || b2.edges[0] != c2 || b2.edges[1] != nil || c2.edges[0] != a2 {
+			t.Errorf("tagged %v: graph is not reconstructed", tagged)
+		}
+	}
+
+	//rolled back objects are encoded again
+	enc := NewEnc()
+	cp := enc.Checkpoint()
+	enc.Ref(c)
+	enc.Rollback(cp)
+	enc.Ref(b)
+	dec := NewDec(enc.Bytes())
+	b2 := DecodeRef[graphNode](dec)
+	if dec.Error() != nil || b2.edges[0].edges[0].edges[1] != b2.edges[0] {
+		t.Errorf("graph is not reconstructed: %v", dec.Error())
+	}
+
+	//rewound objects are decoded again
+	dec.Reset()
+	m := dec.Mark()
+	DecodeRef[graphNode](dec)
+	dec.Rewind(m)
+	if b3 := DecodeRef[graphNode](dec); dec.Error() != nil || b3 == b2 || b3.name != "b" {
+		t.Errorf("expected: nil and got: %v", dec.Error())
+	}
+
+	//invalid references
+	for _, f := range []func(*Enc){
+		func(e *Enc) { e.Uint64(2) },
+		func(e *Enc) { e.Ref(&otherNode{}); e.Uint64(1) },
+	} {
+		enc.Reset()
+		f(enc)
+		dec = NewDec(enc.Bytes())
+		DecodeRef[otherNode](dec)
+		if DecodeRef[graphNode](dec); dec.Error() != errDecode {
+			t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+		}
+	}
+}
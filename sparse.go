@@ -0,0 +1,87 @@
+package encdec
+
+//  Sparse records start with a presence bitmap of their fields followed by present fields only,
+//  so records with many optional fields that are usually zero stay small:
+//
+//	var p encdec.Presence
+//	if u.Name != "" {
+//		p.Set(0)
+//	}
+//	...
+//	enc.Presence(p)
+//	if p.Has(0) {
+//		enc.Str(u.Name)
+//	}
+//
+//  bitmap is encoded like ByteSlice, bit i of byte i/8 is field i, trailing zero bytes are left out.
+
+//  Presence is a bitmap of fields present in a sparse record
+type Presence []byte
+
+//  Set marks field i as present
+func (p *Presence) Set(i int) {
+	for len(*p) <= i/8 {
+		*p = append(*p, 0)
+	}
+	(*p)[i/8] |= 1 << uint(i%8)
+}
+
+//  Has reports whether field i is present
+func (p Presence) Has(i int) bool {
+	return i >= 0 && i/8 < len(p) && p[i/8]&(1<<uint(i%8)) != 0
+}
+
+//  Len returns number of present fields
+func (p Presence) Len() int {
+	n := 0
+	for _, b := range p {
+		for ; b != 0; b &= b - 1 {
+			n++
+		}
+	}
+	return n
+}
+
+//  Max returns index of the last present field or -1
+func (p Presence) Max() int {
+	for i := len(p) - 1; i >= 0; i-- {
+		for j := 7; j >= 0; j-- {
+			if p[i]&(1<<uint(j)) != 0 {
+				return 8*i + j
+			}
+		}
+	}
+	return -1
+}
+
+//  Presence encodes presence bitmap of a sparse record
+func (e *Enc) Presence(p Presence) {
+	if e.err != nil {
+		return
+	}
+	n := len(p)
+	for n > 0 && p[n-1] == 0 {
+		n--
+	}
+	e.tag(KindBytes)
+	e.uvarint(uint64(n))
+	e.encbuf = append(e.encbuf, p[:n]...)
+}
+
+//  Presence decodes presence bitmap of a sparse record, bitmap with trailing zero byte is a decoding error
+//  returned bitmap never aliases input buffer
+func (d *Dec) Presence() Presence {
+	if !d.tag(KindBytes) {
+		return nil
+	}
+	b := d.byteSlice()
+	if d.err != nil {
+		return nil
+	}
+	if len(b) > 0 && b[len(b)-1] == 0 {
+		// only canonical bitmaps, so equal records encode identically
+		d.err = errDecode
+		return nil
+	}
+	return append(Presence(nil), b...)
+}
@@ -0,0 +1,68 @@
+package encdec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPresence(t *testing.T) {
+	var p Presence
+	if p.Has(0) || p.Len() != 0 || p.Max() != -1 {
+		t.Errorf("expected: empty and got: %v", p)
+	}
+	p.Set(1)
+	p.Set(17)
+	p.Set(1)
+	if !p.Has(1) || !p.Has(17) || p.Has(2) || p.Has(-1) || p.Has(100) || p.Len() != 2 || p.Max() != 17 {
+		t.Errorf("expected: [2 0 2] and got: %v", p)
+	}
+
+	//settings of a service, 30 optional fields of which 2 are set
+	fields := make([]uint64, 30)
+	fields[1], fields[17] = 80, 443
+	for _, tagged := range []bool{false, true} {
+		enc := NewEnc()
+		enc.SetTagged(tagged)
+		var p Presence
+		for i, f := range fields {
+			if f != 0 {
+				p.Set(i)
+			}
+		}
+		enc.Presence(append(p, 0, 0))
+		for i, f := range fields {
+			if p.Has(i) {
+				enc.Uint64(f)
+			}
+		}
+		if enc.Error() != nil || enc.Len() > 13 {
+			t.Fatalf("expected: at most 13 bytes and got: %v, %v", enc.Len(), enc.Error())
+		}
+
+		dec := NewDecChunks([][]byte{enc.Bytes()[:2], enc.Bytes()[2:]})
+		dec.SetTagged(tagged)
+		got := make([]uint64, 30)
+		p2 := dec.Presence()
+		for i := range got {
+			if p2.Has(i) {
+				got[i] = dec.Uint64()
+			}
+		}
+		if dec.Error() != nil || dec.Len() != 0 || !bytes.Equal(p, p2) {
+			t.Fatalf("expected: %v and got: %v, %v", p, p2, dec.Error())
+		}
+		for i := range got {
+			if got[i] != fields[i] {
+				t.Errorf("expected: %v and got: %v", fields, got)
+			}
+		}
+	}
+
+	//non canonical bitmap
+	enc := NewEnc()
+	enc.ByteSlice([]byte{1, 0})
+	dec := NewDec(enc.Bytes())
+	if dec.Presence(); dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+}
@@ -0,0 +1,209 @@
+package encdec
+
+import (
+	"errors"
+)
+
+var errKindMismatch = errors.New("encdec: entity kind mismatch")
+
+//  Kind is a type of encoded entity carried by tagged encoding
+type Kind byte
+
+const (
+	KindInvalid Kind = iota
+	KindUint         // Uint64
+	KindInt          // Int64
+	KindFloat        // Float64
+	KindBytes        // ByteSlice
+	KindString       // Str
+	KindNested       // Marshaler
+	KindList         // List followed by its items
+	KindMap          // Map followed by its key, value pairs
+	KindNull         // Null
+	KindEnd          // end of a list of unknown length (see EncodeSeq)
+	KindEnum         // value of registered enum (see RegisterEnum)
+)
+
+var kindNames = [...]string{"invalid", "uint", "int", "float", "bytes", "string", "nested", "list", "map", "null", "end", "enum"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return kindNames[KindInvalid]
+}
+
+//  tagBase is added to Kind to get its tag byte, so tags never collide with entity header bytes or end marker
+const tagBase = 0x10
+
+//  SetTagged turns tagged encoding on or off
+//  in tagged mode every entity is preceded by a tag of its kind, so the stream can be verified and walked without knowing its layout
+func (e *Enc) SetTagged(on bool) {
+	e.tagged = on
+}
+
+//  tag encodes a kind tag into buffer in tagged mode
+func (e *Enc) tag(k Kind) {
+	if e.tagged {
+		e.encbuf = append(e.encbuf, tagBase+byte(k))
+	}
+}
+
+//  Null encodes a null entity into buffer, available in tagged mode only
+func (e *Enc) Null() {
+	if e.err != nil {
+		return
+	}
+	if !e.tagged {
+		e.err = errEncode
+		return
+	}
+	e.tag(KindNull)
+}
+
+//  List encodes a start of list of n entities into buffer
+//  untagged encoding is the same as of Uint64(n)
+func (e *Enc) List(n int) {
+	e.count(KindList, n)
+}
+
+//  Map encodes a start of map of n key, value pairs into buffer
+//  untagged encoding is the same as of Uint64(n)
+func (e *Enc) Map(n int) {
+	e.count(KindMap, n)
+}
+
+func (e *Enc) count(k Kind, n int) {
+	if e.err != nil {
+		return
+	}
+	if n < 0 {
+		e.err = errEncode
+		return
+	}
+	e.tag(k)
+	e.uvarint(uint64(n))
+}
+
+//  SetTagged turns tagged decoding on or off, it has to match encoding of decoded data
+//  in tagged mode decoding of an entity of other kind than requested fails
+func (d *Dec) SetTagged(on bool) {
+	d.tagged = on
+}
+
+//  tag decodes kind tag k from buffer in tagged mode
+func (d *Dec) tag(k Kind) bool {
+	if d.err != nil {
+		return false
+	}
+	if !d.tagged {
+		return true
+	}
+	d.need(1)
+	if d.i >= len(d.decbuf) || d.i < 0 /*overflow*/ {
+		d.err = errNoDecData
+		return false
+	}
+	if d.decbuf[d.i] != tagBase+byte(k) {
+		d.err = errKindMismatch
+		return false
+	}
+	d.i++
+	return true
+}
+
+//  Kind returns kind of the next entity without decoding it
+//  KindInvalid is returned in untagged mode, at the end of data or on error
+func (d *Dec) Kind() Kind {
+	if d.err != nil || !d.tagged {
+		return KindInvalid
+	}
+	d.need(1)
+	if d.i >= len(d.decbuf) || d.i < 0 /*overflow*/ {
+		return KindInvalid
+	}
+	b := d.decbuf[d.i]
+	if b == endMarker {
+		return KindEnd
+	}
+	if k := Kind(b - tagBase); b > tagBase && k != KindEnd && int(k) < len(kindNames) {
+		return k
+	}
+	return KindInvalid
+}
+
+//  Null decodes a null entity from buffer if it is next, available in tagged mode only
+func (d *Dec) Null() bool {
+	if d.Kind() != KindNull {
+		return false
+	}
+	d.i++
+	return true
+}
+
+//  List decodes a start of list from buffer and returns number of its entities
+func (d *Dec) List() int {
+	return d.count(KindList, 1)
+}
+
+//  Map decodes a start of map from buffer and returns number of its key, value pairs
+func (d *Dec) Map() int {
+	return d.count(KindMap, 2)
+}
+
+func (d *Dec) count(k Kind, per int) int {
+	if !d.tag(k) {
+		return 0
+	}
+	n := d.uvarint()
+	if d.err != nil {
+		return 0
+	}
+	// every entity takes at least one byte
+	if n > uint64(d.Len()/per) {
+		d.err = errDecodeNotEnoughtData
+		return 0
+	}
+	return int(n)
+}
+
+//  Skip skips next entity including all items of a list or map, available in tagged mode only
+//  and not in dictionary mode, where skipped entities could add to the table
+func (d *Dec) Skip() {
+	if d.err != nil {
+		return
+	}
+	if !d.tagged || d.dict != nil {
+		d.err = errDecode
+		return
+	}
+	for pending := 1; pending > 0 && d.err == nil; pending-- {
+		switch k := d.Kind(); k {
+		case KindUint:
+			d.Uint64()
+		case KindInt:
+			d.Int64()
+		case KindFloat:
+			d.Float64()
+		case KindBytes, KindString, KindNested:
+			d.i++
+			d.byteSlice()
+		case KindList:
+			pending += d.List()
+		case KindMap:
+			pending += 2 * d.Map()
+		case KindNull, KindEnd:
+			d.i++
+		case KindEnum:
+			d.i++
+			d.uvarint()
+			d.varint()
+		default:
+			if d.Len() == 0 {
+				d.err = errNoDecData
+			} else {
+				d.err = errDecode
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package encdec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTaggedEncDec(t *testing.T) {
+	ti := time.Now()
+	enc := NewEnc()
+	enc.SetTagged(true)
+	enc.Uint64(1)
+	enc.Int64(-2)
+	enc.Float64(3.5)
+	enc.ByteSlice([]byte{4})
+	enc.Str("five")
+	enc.Marshaler(ti)
+	enc.List(2)
+	enc.Null()
+	enc.Map(1)
+	enc.Str("k")
+	enc.List(0)
+	enc.Uint64(7)
+	if enc.Error() != nil {
+		t.Fatal(enc.Error())
+	}
+
+	//typed decoding
+	dec := NewDec(enc.Bytes())
+	dec.SetTagged(true)
+	var td time.Time
+	if dec.Uint64() != 1 || dec.Int64() != -2 || dec.Float64() != 3.5 || !bytes.Equal(dec.ByteSlice(), []byte{4}) || dec.Str() != "five" {
+		t.Fatalf("decoding failed: %v", dec.Error())
+	}
+	dec.Unmarshaler(&td)
+	if !td.Equal(ti) || dec.List() != 2 || !dec.Null() || dec.Map() != 1 || dec.Str() != "k" || dec.List() != 0 || dec.Uint64() != 7 || dec.Error() != nil {
+		t.Fatalf("decoding failed: %v", dec.Error())
+	}
+
+	//walking without knowledge of layout
+	exp := []Kind{KindUint, KindInt, KindFloat, KindBytes, KindString, KindNested, KindList, KindUint}
+	dec.Reset()
+	for i, k := range exp {
+		if g := dec.Kind(); g != k {
+			t.Fatalf("entity %v: expected: %v and got: %v", i, k, g)
+		}
+		dec.Skip()
+	}
+	if dec.Error() != nil || dec.Len() != 0 || dec.Kind() != KindInvalid {
+		t.Errorf("expected: end of data and got: %v, %v", dec.Len(), dec.Error())
+	}
+
+	//kind mismatch
+	dec.Reset()
+	dec.Int64()
+	if dec.Error() != errKindMismatch {
+		t.Errorf("expected: %v and got: %v", errKindMismatch, dec.Error())
+	}
+	dec.Reset()
+	dec.Uint64()
+	if dec.Null() || dec.ByteSlice() != nil || dec.Error() != errKindMismatch {
+		t.Errorf("expected: %v and got: %v", errKindMismatch, dec.Error())
+	}
+
+	//corrupted list length
+	dec = NewDec([]byte{tagBase + byte(KindList), 1, 100})
+	dec.SetTagged(true)
+	dec.Skip()
+	if dec.Error() != errDecodeNotEnoughtData {
+		t.Errorf("expected: %v and got: %v", errDecodeNotEnoughtData, dec.Error())
+	}
+
+	//untagged compatibility
+	enc = NewEnc()
+	enc.Str("abc")
+	enc.List(3)
+	cp := enc.Checkpoint()
+	enc.Null()
+	if enc.Error() != errEncode {
+		t.Errorf("expected: %v and got: %v", errEncode, enc.Error())
+	}
+	enc.Rollback(cp)
+	dec = NewDec(enc.Bytes())
+	if string(dec.ByteSlice()) != "abc" || dec.Uint64() != 3 || dec.Kind() != KindInvalid {
+		t.Error("untagged decoding failed")
+	}
+	dec.Skip()
+	if dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+}
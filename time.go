@@ -0,0 +1,263 @@
+package encdec
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errTimeZone = errors.New("encdec: unknown time zone")
+
+//  Time is encoded as Int64 of unix seconds shifted left by 4 bits carrying zone form and unit of fraction,
+//  followed by Uint64 fraction of second in that unit (left out for whole seconds) and zone data:
+//  Int64 offset for timeOffset, Str name and Int64 offset for timeName.
+//  Duration is encoded as Int64 shifted left by 2 bits carrying its unit.
+//  Unit is the coarsest one representing truncated value exactly, so round values are short.
+const (
+	timeUTC    = 0
+	timeOffset = 1
+	timeName   = 2
+
+	timeMaxSec = 1 << 59
+)
+
+//  timeUnits are units of fractions and durations by their codes
+var timeUnits = [...]time.Duration{time.Second, time.Millisecond, time.Microsecond, time.Nanosecond}
+
+//  TimeZone says what Enc.Time keeps of location of encoded time
+type TimeZone int
+
+const (
+	//  ZoneUTC drops location, time is decoded in UTC (default)
+	ZoneUTC TimeZone = iota
+	//  ZoneOffset keeps zone offset, time is decoded in a fixed zone
+	ZoneOffset
+	//  ZoneName keeps name of location, time is decoded in it if decoder can load it or in a fixed zone otherwise
+	//  time.Local has no portable name, it is kept as offset
+	ZoneName
+)
+
+//  maxLocations bounds number of cached locations, so that data with many distinct zones can not grow the cache
+const maxLocations = 1 << 10
+
+var (
+	//  locations caches locations of decoded zone names, nil for names that can not be loaded,
+	//  and fixed zones by zoneKey
+	locations  sync.Map
+	nlocations atomic.Int32
+)
+
+//  zoneKey is name and offset of a fixed zone
+type zoneKey struct {
+	name string
+	off  int
+}
+
+//  SetTimePrecision sets precision of encoded times and durations, p is rounded down to
+//  a second, millisecond, microsecond or nanosecond (default), values are truncated to it
+//  decoder needs no setting, millisecond timestamps cost about 10 bytes
+func (e *Enc) SetTimePrecision(p time.Duration) {
+	e.tprec = time.Nanosecond
+	for _, u := range timeUnits {
+		if u <= p {
+			e.tprec = u
+			break
+		}
+	}
+}
+
+//  SetTimeZone sets what Enc.Time keeps of location of encoded time
+func (e *Enc) SetTimeZone(z TimeZone) {
+	e.tzone = z
+}
+
+//  precision returns precision of times and durations
+func (e *Enc) precision() time.Duration {
+	if e.tprec == 0 {
+		return time.Nanosecond
+	}
+	return e.tprec
+}
+
+//  unit returns code of the coarsest unit x is multiple of
+func unit(x int64) uint64 {
+	c := uint64(0)
+	for x%int64(timeUnits[c]) != 0 {
+		c++
+	}
+	return c
+}
+
+//  Time encodes a time.Time into buffer with precision and zone form of encoder,
+//  time outside of range of 2^59 seconds around 1970 is an encoding error
+func (e *Enc) Time(t time.Time) {
+	if e.err != nil {
+		return
+	}
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	if sec < -timeMaxSec || sec >= timeMaxSec {
+		e.err = errEncode
+		return
+	}
+	nsec -= nsec % int64(e.precision())
+	zone := uint64(timeUTC)
+	name, off := t.Zone()
+	if e.tzone != ZoneUTC && t.Location() != time.UTC {
+		zone = timeOffset
+		if name = t.Location().String(); e.tzone == ZoneName && name != "Local" {
+			zone = timeName
+		}
+	}
+	c := unit(nsec)
+	e.Int64(sec<<4 | int64(zone<<2|c))
+	if c != 0 {
+		e.Uint64(uint64(nsec / int64(timeUnits[c])))
+	}
+	switch zone {
+	case timeName:
+		e.Str(name)
+		fallthrough
+	case timeOffset:
+		e.Int64(int64(off))
+	}
+}
+
+//  Time decodes a time.Time from buffer
+func (d *Dec) Time() time.Time {
+	x := d.Int64()
+	if d.err != nil {
+		return time.Time{}
+	}
+	zone, c := (x>>2)&3, x&3
+	var nsec uint64
+	if c != 0 {
+		nsec = d.Uint64()
+		if nsec >= uint64(time.Second/timeUnits[c]) {
+			d.err = errDecode
+			return time.Time{}
+		}
+		nsec *= uint64(timeUnits[c])
+	}
+	t := time.Unix(x>>4, int64(nsec))
+	var name string
+	switch zone {
+	case timeUTC:
+		return t.UTC()
+	case timeName:
+		name = d.Str()
+		fallthrough
+	case timeOffset:
+		off := d.Int64()
+		if d.err != nil {
+			return time.Time{}
+		}
+		if off <= -86400 || off >= 86400 {
+			d.err = errDecode
+			return time.Time{}
+		}
+		return t.In(location(name, int(off)))
+	}
+	d.err = errDecode
+	return time.Time{}
+}
+
+//  location returns location of zone name, or fixed zone of name and offset if it can not be loaded
+func location(name string, off int) *time.Location {
+	if name != "" {
+		if loc := loadLocation(name); loc != nil {
+			return loc
+		}
+	}
+	key := zoneKey{name, off}
+	if loc, ok := locations.Load(key); ok {
+		return loc.(*time.Location)
+	}
+	loc := time.FixedZone(name, off)
+	cacheLocation(key, loc)
+	return loc
+}
+
+//  loadLocation returns location of zone name or nil if it can not be loaded, failed lookups are cached too
+//  "Local" is not loaded as it means a different zone to decoder than to encoder
+func loadLocation(name string) *time.Location {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		loc = nil
+	}
+	cacheLocation(name, loc)
+	return loc
+}
+
+//  cacheLocation stores loc under key unless the cache is full
+func cacheLocation(key interface{}, loc *time.Location) {
+	if nlocations.Load() >= maxLocations {
+		return
+	}
+	if _, loaded := locations.LoadOrStore(key, loc); !loaded {
+		nlocations.Add(1)
+	}
+}
+
+//  Duration encodes a time.Duration into buffer truncated to precision of encoder
+func (e *Enc) Duration(x time.Duration) {
+	if e.err != nil {
+		return
+	}
+	x = x.Truncate(e.precision())
+	c := unit(int64(x))
+	u := int64(x / timeUnits[c])
+	if u < -1<<61 || u >= 1<<61 {
+		// over 73 years in nanoseconds
+		x = x.Truncate(time.Microsecond)
+		c = unit(int64(x))
+		u = int64(x / timeUnits[c])
+	}
+	e.Int64(u<<2 | int64(c))
+}
+
+//  Duration decodes a time.Duration from buffer, duration out of its range is a decoding error
+func (d *Dec) Duration() time.Duration {
+	x := d.Int64()
+	if d.err != nil {
+		return 0
+	}
+	u, c := x>>2, timeUnits[x&3]
+	if u > int64(1<<63-1)/int64(c) || u < -1<<63/int64(c) {
+		d.err = errDecode
+		return 0
+	}
+	return time.Duration(u) * c
+}
+
+//  Location encodes a time.Location into buffer by its name
+func (e *Enc) Location(loc *time.Location) {
+	if e.err != nil {
+		return
+	}
+	if loc == nil {
+		e.err = errEncode
+		return
+	}
+	e.Str(loc.String())
+}
+
+//  Location decodes a time.Location from buffer, name decoder can not load is an error
+//  "Local" is decoded as time.Local of decoder
+func (d *Dec) Location() *time.Location {
+	name := d.Str()
+	if d.err != nil {
+		return nil
+	}
+	if name == "Local" {
+		return time.Local
+	}
+	loc := loadLocation(name)
+	if loc == nil {
+		d.err = errTimeZone
+	}
+	return loc
+}
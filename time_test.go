@@ -0,0 +1,127 @@
+package encdec
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTime(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	ts := time.Date(2024, 3, 10, 12, 30, 15, 123456789, ny)
+	for _, tagged := range []bool{false, true} {
+		for _, tc := range []struct {
+			prec     time.Duration
+			zone     TimeZone
+			in       time.Time
+			expected time.Time
+		}{
+			{0, ZoneUTC, ts, ts.UTC()},
+			{time.Millisecond, ZoneUTC, ts, ts.Truncate(time.Millisecond).UTC()},
+			{time.Second, ZoneOffset, ts, ts.Truncate(time.Second).In(time.FixedZone("", -4*3600))},
+			{time.Microsecond + 1, ZoneName, ts, ts.Truncate(time.Microsecond)},
+			{0, ZoneName, ts.UTC(), ts.UTC()},
+			{0, ZoneName, time.Date(2024, 1, 1, 0, 0, 0, 0, time.FixedZone("XYZ", 3600)), time.Date(2024, 1, 1, 0, 0, 0, 0, time.FixedZone("XYZ", 3600))},
+			{0, ZoneOffset, time.Time{}, time.Time{}},
+			{0, ZoneUTC, time.Unix(-1, 5e8), time.Unix(-1, 5e8).UTC()},
+		} {
+			enc := NewEnc()
+			enc.SetTagged(tagged)
+			enc.SetTimePrecision(tc.prec)
+			enc.SetTimeZone(tc.zone)
+			enc.Time(tc.in)
+			enc.Uint64(7)
+			dec := NewDec(enc.Bytes())
+			dec.SetTagged(tagged)
+			tm := dec.Time()
+			if !tm.Equal(tc.expected) || tm.String() != tc.expected.String() || dec.Uint64() != 7 || dec.Error() != nil {
+				t.Errorf("expected: %v and got: %v, %v", tc.expected, tm, dec.Error())
+			}
+		}
+	}
+
+	//millisecond timestamp is cheaper than MarshalBinary
+	enc := NewEnc()
+	enc.SetTimePrecision(time.Millisecond)
+	enc.Time(ts)
+	if enc.Len() > 10 {
+		t.Errorf("expected: %v and got: %v", 10, enc.Len())
+	}
+
+	//invalid fraction and zone
+	for _, x := range []int64{1<<4 | 1, 1<<4 | 3<<2} {
+		enc.Reset()
+		enc.Int64(x)
+		enc.Uint64(1000)
+		dec := NewDec(enc.Bytes())
+		if dec.Time(); dec.Error() != errDecode {
+			t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for _, tc := range []struct {
+		prec     time.Duration
+		in       time.Duration
+		expected time.Duration
+		size     int
+	}{
+		{0, 1500 * time.Millisecond, 1500 * time.Millisecond, 3},
+		{0, -time.Hour, -time.Hour, 4},
+		{0, 1, 1, 2},
+		{time.Second, 2500 * time.Millisecond, 2 * time.Second, 2},
+		{time.Millisecond, -time.Nanosecond, 0, 2},
+		{0, 1<<63 - 1, (1<<63 - 1) / 1000 * 1000, 10},
+		{0, -1 << 63, -1 << 63 / 1000 * 1000, 10},
+	} {
+		enc := NewEnc()
+		enc.SetTimePrecision(tc.prec)
+		enc.Duration(tc.in)
+		dec := NewDec(enc.Bytes())
+		if d := dec.Duration(); d != tc.expected || dec.Error() != nil || enc.Len() != tc.size {
+			t.Errorf("expected: %v (%v bytes) and got: %v (%v bytes), %v", tc.expected, tc.size, d, enc.Len(), dec.Error())
+		}
+	}
+	enc := NewEnc()
+	enc.Int64(1 << 61)
+	dec := NewDec(enc.Bytes())
+	if dec.Duration(); dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+}
+
+func TestLocation(t *testing.T) {
+	enc := NewEnc()
+	enc.Location(time.UTC)
+	enc.Location(time.Local)
+	enc.Str("Nowhere/Atlantis")
+	dec := NewDec(enc.Bytes())
+	if loc := dec.Location(); loc != time.UTC {
+		t.Errorf("expected: %v and got: %v", time.UTC, loc)
+	}
+	if loc := dec.Location(); loc != time.Local {
+		t.Errorf("expected: %v and got: %v", time.Local, loc)
+	}
+	if dec.Location(); dec.Error() != errTimeZone {
+		t.Errorf("expected: %v and got: %v", errTimeZone, dec.Error())
+	}
+	if enc.Location(nil); enc.Error() != errEncode {
+		t.Errorf("expected: %v and got: %v", errEncode, enc.Error())
+	}
+
+	//failed lookups are cached, cache is bounded
+	if loc, ok := locations.Load("Nowhere/Atlantis"); !ok || loc.(*time.Location) != nil {
+		t.Errorf("expected: cached nil and got: %v, %v", loc, ok)
+	}
+	for off := 0; off < 2*maxLocations; off++ {
+		if _, o := time.Unix(0, 0).In(location("Nowhere/Atlantis", off)).Zone(); o != off {
+			t.Fatalf("expected: %v and got: %v", off, o)
+		}
+	}
+	if n := nlocations.Load(); n > maxLocations {
+		t.Errorf("expected: %v and got: %v", maxLocations, n)
+	}
+}
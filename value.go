@@ -0,0 +1,576 @@
+package encdec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+//  Value is a generic tree of decoded entities, it allows to inspect, modify and re-encode data without their Go types
+//  scalars of untagged streams can not be told apart, they are decoded as KindUint holding raw varint
+//  and Int and Float reinterpret it the way Int64 and Float64 encoded it
+type Value struct {
+	kind  Kind
+	num   uint64
+	raw   []byte
+	items []Value
+	mode  byte
+	enum  uint64
+}
+
+//  encoding of nested value payload
+const (
+	modeInherit byte = iota
+	modeUntagged
+	modeTagged
+)
+
+//  maxValueDepth limits nesting of decoded lists, maps and messages
+const maxValueDepth = 1000
+
+//  UintValue returns a value of KindUint
+func UintValue(x uint64) Value {
+	return Value{kind: KindUint, num: x}
+}
+
+//  IntValue returns a value of KindInt
+func IntValue(x int64) Value {
+	return Value{kind: KindInt, num: uint64(x)}
+}
+
+//  FloatValue returns a value of KindFloat
+func FloatValue(x float64) Value {
+	return Value{kind: KindFloat, num: math.Float64bits(x)}
+}
+
+//  BytesValue returns a value of KindBytes
+func BytesValue(x []byte) Value {
+	return Value{kind: KindBytes, raw: x}
+}
+
+//  StringValue returns a value of KindString
+func StringValue(x string) Value {
+	return Value{kind: KindString, raw: []byte(x)}
+}
+
+//  EnumValue returns a value of KindEnum, x of enum registered under id
+func EnumValue(id uint64, x int64) Value {
+	return Value{kind: KindEnum, num: uint64(x), enum: id}
+}
+
+//  NullValue returns a value of KindNull
+func NullValue() Value {
+	return Value{kind: KindNull}
+}
+
+//  ListValue returns a value of KindList
+func ListValue(items ...Value) Value {
+	return Value{kind: KindList, items: items}
+}
+
+//  MapValue returns a value of KindMap, kv holds keys and values alternately
+func MapValue(kv ...Value) Value {
+	if len(kv)%2 != 0 {
+		kv = append(kv, NullValue())
+	}
+	return Value{kind: KindMap, items: kv}
+}
+
+//  NestedValue returns a value of KindNested, a message made of items
+func NestedValue(items ...Value) Value {
+	return Value{kind: KindNested, items: items}
+}
+
+//  Kind returns kind of value
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+//  Uint returns value of KindUint
+func (v Value) Uint() uint64 {
+	if v.kind != KindUint {
+		return 0
+	}
+	return v.num
+}
+
+//  Int returns value of KindInt, KindEnum or KindUint decoded the way Int64 encodes it
+func (v Value) Int() int64 {
+	switch v.kind {
+	case KindInt, KindEnum:
+		return int64(v.num)
+	case KindUint:
+		x := int64(v.num >> 1)
+		if v.num&1 != 0 {
+			x = ^x
+		}
+		return x
+	}
+	return 0
+}
+
+//  Enum returns enum id of KindEnum value
+func (v Value) Enum() uint64 {
+	return v.enum
+}
+
+//  Float returns value of KindFloat or KindUint decoded the way Float64 encodes it
+func (v Value) Float() float64 {
+	if v.kind != KindFloat && v.kind != KindUint {
+		return 0
+	}
+	return math.Float64frombits(v.num)
+}
+
+//  Bytes returns value of KindBytes, KindString or payload of KindNested message that could not be parsed
+func (v Value) Bytes() []byte {
+	return v.raw
+}
+
+//  Str returns value of KindString or KindBytes as a string
+func (v Value) Str() string {
+	return string(v.raw)
+}
+
+//  Len returns number of items of a list or message, or number of pairs of a map
+func (v Value) Len() int {
+	if v.kind == KindMap {
+		return len(v.items) / 2
+	}
+	return len(v.items)
+}
+
+//  Items returns items of a list or message, or alternating keys and values of a map
+//  returned slice is shared with the value, so items can be modified in place
+func (v Value) Items() []Value {
+	return v.items
+}
+
+//  Index returns i-th item of a list or message
+func (v Value) Index(i int) Value {
+	return v.items[i]
+}
+
+//  Key returns i-th key of a map
+func (v Value) Key(i int) Value {
+	return v.items[2*i]
+}
+
+//  Elem returns i-th value of a map
+func (v Value) Elem(i int) Value {
+	return v.items[2*i+1]
+}
+
+//  Lookup returns value of a map stored under key equal to k
+func (v Value) Lookup(k Value) (Value, bool) {
+	if v.kind != KindMap {
+		return Value{}, false
+	}
+	for i := 0; i+1 < len(v.items); i += 2 {
+		if v.items[i].Equal(k) {
+			return v.items[i+1], true
+		}
+	}
+	return Value{}, false
+}
+
+//  Equal reports whether v and w hold the same data, floats are compared bitwise
+func (v Value) Equal(w Value) bool {
+	if v.kind != w.kind || v.num != w.num || v.enum != w.enum || !bytes.Equal(v.raw, w.raw) || len(v.items) != len(w.items) {
+		return false
+	}
+	for i := range v.items {
+		if !v.items[i].Equal(w.items[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (v Value) String() string {
+	var sb strings.Builder
+	v.format(&sb)
+	return sb.String()
+}
+
+func (v Value) format(sb *strings.Builder) {
+	switch v.kind {
+	case KindUint:
+		fmt.Fprintf(sb, "%d", v.num)
+	case KindInt:
+		fmt.Fprintf(sb, "%d", int64(v.num))
+	case KindFloat:
+		fmt.Fprintf(sb, "%g", v.Float())
+	case KindBytes:
+		fmt.Fprintf(sb, "%x", v.raw)
+	case KindString:
+		fmt.Fprintf(sb, "%q", v.raw)
+	case KindEnum:
+		sb.WriteString(enumName(v.enum, int64(v.num)))
+	case KindNull:
+		sb.WriteString("null")
+	case KindEnd:
+		sb.WriteString("end")
+	case KindList, KindMap, KindNested:
+		open, sep, end := "[", ", ", "]"
+		if v.kind == KindMap {
+			open, end = "{", "}"
+		} else if v.kind == KindNested {
+			open, end = "(", ")"
+			if v.items == nil && v.raw != nil {
+				fmt.Fprintf(sb, "(%x)", v.raw)
+				return
+			}
+		}
+		sb.WriteString(open)
+		for i, it := range v.items {
+			if i > 0 {
+				if v.kind == KindMap && i%2 == 1 {
+					sb.WriteString(": ")
+				} else {
+					sb.WriteString(sep)
+				}
+			}
+			it.format(sb)
+		}
+		sb.WriteString(end)
+	default:
+		sb.WriteString("invalid")
+	}
+}
+
+//  MarshalBinary implements encoding.BinaryMarshaler, it encodes items of a message
+//  in the encoding it was decoded from (untagged for constructed messages)
+func (v Value) MarshalBinary() ([]byte, error) {
+	if v.kind != KindNested {
+		return nil, errEncode
+	}
+	return v.payload(v.mode == modeTagged)
+}
+
+//  UnmarshalBinary implements encoding.BinaryUnmarshaler, it decodes data as a message
+//  trying tagged encoding first and untagged one next, unparsable data are kept as raw payload
+func (v *Value) UnmarshalBinary(data []byte) error {
+	*v = newValueParser(len(data)).nested(data, 0)
+	return nil
+}
+
+//  DecodeValue decodes all remaining entities of dec as a message (KindNested value)
+//  tagged streams are decoded exactly, untagged ones heuristically:
+//  of all readings of the stream as scalars and length prefixed blobs the one with fewest entities is taken
+//  and blobs that are well formed streams themselves are decoded as nested messages
+func DecodeValue(dec *Dec) Value {
+	if dec.err != nil {
+		return Value{}
+	}
+	var data []byte
+	if dec.orig == nil {
+		data = dec.decbuf[dec.i:]
+	} else {
+		dec.need(dec.Len())
+		data = dec.decbuf[dec.i:]
+	}
+	var (
+		v  Value
+		ok bool
+	)
+	if dec.tagged {
+		v, ok = newValueParser(len(data)).tagged(data, 0)
+	} else {
+		v, ok = newValueParser(len(data)).untagged(data, 0)
+	}
+	if !ok {
+		dec.err = errDecode
+		return Value{}
+	}
+	dec.i += len(data)
+	return v
+}
+
+//  EncodeValue encodes v into enc, a message (KindNested value) is written as its items
+//  so EncodeValue(enc, DecodeValue(dec)) reproduces the decoded stream
+func EncodeValue(enc *Enc, v Value) {
+	if v.kind == KindNested && v.items == nil && v.raw != nil {
+		if enc.err == nil {
+			enc.encbuf = append(enc.encbuf, v.raw...)
+		}
+		return
+	}
+	if v.kind == KindNested {
+		for _, it := range v.items {
+			encodeValue(enc, it)
+		}
+		return
+	}
+	encodeValue(enc, v)
+}
+
+func encodeValue(enc *Enc, v Value) {
+	if enc.err != nil {
+		return
+	}
+	switch v.kind {
+	case KindUint:
+		enc.Uint64(v.num)
+	case KindInt:
+		enc.Int64(int64(v.num))
+	case KindFloat:
+		enc.Float64(v.Float())
+	case KindBytes:
+		if v.raw == nil {
+			enc.ByteSlice([]byte{})
+		} else {
+			enc.ByteSlice(v.raw)
+		}
+	case KindString:
+		enc.tag(KindString)
+		enc.bytes(v.raw)
+	case KindEnum:
+		if !enc.tagged {
+			enc.Int64(int64(v.num))
+			break
+		}
+		enc.tag(KindEnum)
+		enc.uvarint(v.enum)
+		enc.varint(int64(v.num))
+	case KindNull:
+		enc.Null()
+	case KindEnd:
+		enc.end()
+	case KindList, KindMap:
+		enc.count(v.kind, v.Len())
+		for _, it := range v.items {
+			encodeValue(enc, it)
+		}
+	case KindNested:
+		tagged := enc.tagged
+		if v.mode != modeInherit {
+			tagged = v.mode == modeTagged
+		}
+		var buf []byte
+		buf, enc.err = v.payload(tagged)
+		if enc.err == nil {
+			enc.tag(KindNested)
+			enc.bytes(buf)
+		}
+	default:
+		enc.err = errEncode
+	}
+}
+
+//  payload encodes items of a message
+func (v Value) payload(tagged bool) ([]byte, error) {
+	if v.items == nil && v.raw != nil {
+		return v.raw, nil
+	}
+	enc := NewEnc()
+	enc.SetTagged(tagged)
+	EncodeValue(enc, v)
+	return enc.Bytes(), enc.Error()
+}
+
+//  valueParser decodes nested payloads of a Value tree, untagged payloads may be scanned once per level of nesting,
+//  so total work is limited to a multiple of input size and payloads beyond the limit are kept raw
+type valueParser struct {
+	work int // bytes left to scan
+}
+
+const (
+	valueWorkFactor = 16
+	valueWorkMin    = 1 << 16
+)
+
+func newValueParser(n int) *valueParser {
+	return &valueParser{work: valueWorkFactor*n + valueWorkMin}
+}
+
+//  scan takes n bytes of work, it reports false when the limit is exhausted
+func (p *valueParser) scan(n int) bool {
+	if p.work < n {
+		p.work = 0
+		return false
+	}
+	p.work -= n
+	return true
+}
+
+//  nested decodes payload of a nested message, payload is copied only if it is kept raw
+func (p *valueParser) nested(data []byte, depth int) Value {
+	if len(data) > 0 && p.scan(len(data)) {
+		if v, ok := p.tagged(data, depth); ok && canonicalTagged(data) {
+			return v
+		}
+		if v, ok := p.untagged(data, depth); ok {
+			return v
+		}
+	}
+	return Value{kind: KindNested, raw: append([]byte{}, data...)}
+}
+
+//  tagged decodes data as a tagged message
+func (p *valueParser) tagged(data []byte, depth int) (Value, bool) {
+	if depth > maxValueDepth {
+		return Value{}, false
+	}
+	dec := NewDec(data)
+	dec.SetTagged(true)
+	v := Value{kind: KindNested, items: []Value{}, mode: modeTagged}
+	for dec.Len() > 0 && dec.err == nil {
+		v.items = append(v.items, p.decodeTagged(dec, depth+1))
+	}
+	return v, dec.err == nil
+}
+
+//  decodeTagged decodes next entity of a tagged stream
+func (p *valueParser) decodeTagged(dec *Dec, depth int) Value {
+	if depth > maxValueDepth {
+		dec.err = errDecode
+		return Value{}
+	}
+	switch k := dec.Kind(); k {
+	case KindUint:
+		return UintValue(dec.Uint64())
+	case KindInt:
+		return IntValue(dec.Int64())
+	case KindFloat:
+		return FloatValue(dec.Float64())
+	case KindBytes:
+		return BytesValue(dec.ByteSliceCopy())
+	case KindString:
+		return StringValue(dec.Str())
+	case KindNull, KindEnd:
+		dec.i++
+		return Value{kind: k}
+	case KindEnum:
+		dec.i++
+		id := dec.uvarint()
+		return EnumValue(id, dec.varint())
+	case KindList, KindMap:
+		v := Value{kind: k}
+		n := dec.count(k, 1)
+		if k == KindMap {
+			n *= 2
+		}
+		v.items = make([]Value, 0, n)
+		for i := 0; i < n && dec.err == nil; i++ {
+			v.items = append(v.items, p.decodeTagged(dec, depth+1))
+		}
+		return v
+	case KindNested:
+		dec.i++
+		data := dec.byteSlice()
+		if dec.err != nil {
+			return Value{}
+		}
+		return p.nested(data, depth)
+	}
+	dec.err = errDecode
+	return Value{}
+}
+
+//  canonicalTagged reports whether all varints of tagged message data are minimally encoded,
+//  so that message parsed from data encodes back to it (nested payloads are checked when they are parsed)
+func canonicalTagged(data []byte) bool {
+	for i := 0; i < len(data); {
+		k := Kind(data[i] - tagBase)
+		i++
+		n := 1
+		switch {
+		case data[i-1] == endMarker || k == KindNull:
+			continue
+		case k == KindEnum:
+			n = 2
+		}
+		for ; n > 0; n-- {
+			next, u, ok := untaggedScalar(data, i)
+			if !ok || data[i] == endMarker {
+				return false
+			}
+			i = next
+			if k == KindBytes || k == KindString || k == KindNested {
+				i += int(u)
+			}
+		}
+	}
+	return true
+}
+
+//  untagged decodes data as an untagged message
+//  only minimally encoded varints are accepted, so message parsed from data encodes back to it
+func (p *valueParser) untagged(data []byte, depth int) (Value, bool) {
+	if depth > maxValueDepth {
+		return Value{}, false
+	}
+	// cost[i] is the least number of entities data[i:] can be read as, -1 if it is not a well formed stream
+	// blob[i] tells the entity at data[i] is read as a length prefixed blob
+	cost := make([]int, len(data)+1)
+	blob := make([]bool, len(data))
+	for i := len(data) - 1; i >= 0; i-- {
+		cost[i] = -1
+		next, u, ok := untaggedScalar(data, i)
+		if !ok {
+			continue
+		}
+		if cost[next] >= 0 {
+			cost[i] = cost[next] + 1
+		}
+		if data[i] != endMarker && u <= uint64(len(data)-next) {
+			// the reading explaining data with fewer entities wins, scalar one on a tie
+			if c := cost[next+int(u)]; c >= 0 && (cost[i] < 0 || c+1 < cost[i]) {
+				cost[i] = c + 1
+				blob[i] = true
+			}
+		}
+	}
+	if cost[0] < 0 {
+		return Value{}, false
+	}
+	v := Value{kind: KindNested, items: make([]Value, 0, cost[0]), mode: modeUntagged}
+	var blobs []int // offsets of blobs by items
+	for i := 0; i < len(data); {
+		next, u, _ := untaggedScalar(data, i)
+		switch {
+		case data[i] == endMarker:
+			v.items = append(v.items, Value{kind: KindEnd})
+			i = next
+		case !blob[i]:
+			v.items = append(v.items, UintValue(u))
+			i = next
+		default:
+			blobs = append(blobs, len(v.items), next, next+int(u))
+			v.items = append(v.items, Value{})
+			i = next + int(u)
+		}
+	}
+	// tables are not needed while blobs are parsed
+	cost, blob = nil, nil
+	for j := 0; j < len(blobs); j += 3 {
+		b := data[blobs[j+1]:blobs[j+2]]
+		nv := p.nested(b, depth+1)
+		if nv.items == nil {
+			nv = BytesValue(nv.raw)
+		}
+		v.items[blobs[j]] = nv
+	}
+	return v, true
+}
+
+//  untaggedScalar reads a varint entity or end marker at data[i:]
+func untaggedScalar(data []byte, i int) (next int, u uint64, ok bool) {
+	h := int(data[i])
+	if h == endMarker {
+		return i + 1, 0, true
+	}
+	if h > binary.MaxVarintLen64 || i+1+h > len(data) {
+		return 0, 0, false
+	}
+	u, n := binary.Uvarint(data[i+1 : i+1+h])
+	if n != h || h != (bits.Len64(u|1)+6)/7 {
+		// not minimally encoded
+		return 0, 0, false
+	}
+	return i + 1 + h, u, true
+}
@@ -0,0 +1,163 @@
+package encdec
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestValueTagged(t *testing.T) {
+	inner := NewEnc()
+	inner.SetTagged(true)
+	inner.Str("inner")
+	inner.Int64(-1)
+
+	enc := NewEnc()
+	enc.SetTagged(true)
+	enc.Uint64(1)
+	enc.Float64(0.5)
+	enc.ByteSlice([]byte{1, 2})
+	enc.Map(2)
+	enc.Str("a")
+	enc.List(2)
+	enc.Null()
+	enc.Int64(-3)
+	enc.Str("b")
+	enc.ByteSlice(inner.Bytes())
+	enc.Marshaler(&Value{kind: KindNested, raw: inner.Bytes()})
+	enc.Marshaler(time.Unix(1, 0))
+	data := enc.Bytes()
+
+	dec := NewDec(data)
+	dec.SetTagged(true)
+	v := DecodeValue(dec)
+	if dec.Error() != nil || v.Kind() != KindNested || v.Len() != 6 {
+		t.Fatalf("decoding failed: %v %v", v, dec.Error())
+	}
+	if v.Index(0).Uint() != 1 || v.Index(1).Float() != 0.5 || !bytes.Equal(v.Index(2).Bytes(), []byte{1, 2}) {
+		t.Errorf("unexpected scalars: %v", v)
+	}
+	m := v.Index(3)
+	if l, ok := m.Lookup(StringValue("a")); !ok || !l.Equal(ListValue(NullValue(), IntValue(-3))) {
+		t.Errorf("unexpected map: %v", m)
+	}
+	if n := v.Index(4); n.Kind() != KindNested || n.Index(0).Str() != "inner" || n.Index(1).Int() != -1 {
+		t.Errorf("unexpected nested: %v", n)
+	}
+	if v.Index(5).Kind() != KindNested || v.Index(5).Items() != nil {
+		t.Errorf("expected raw payload and got: %v", v.Index(5))
+	}
+	if s := m.String(); s != `{"a": [null, -3], "b": 150105696e6e6572120101}` {
+		t.Errorf("unexpected format: %v", s)
+	}
+
+	//round trip and modification
+	re := NewEnc()
+	re.SetTagged(true)
+	EncodeValue(re, v)
+	if !bytes.Equal(re.Bytes(), data) {
+		t.Fatalf("expected: %v and got: %v", data, re.Bytes())
+	}
+	m.Items()[1] = ListValue(StringValue("x"))
+	re.Reset()
+	EncodeValue(re, v)
+	dec = NewDec(re.Bytes())
+	dec.SetTagged(true)
+	w := DecodeValue(dec)
+	if l, _ := w.Index(3).Lookup(StringValue("a")); l.Index(0).Str() != "x" || !w.Equal(v) {
+		t.Errorf("modification was not encoded: %v", w)
+	}
+
+	//truncated data
+	dec = NewDec(data[:len(data)-1])
+	dec.SetTagged(true)
+	if DecodeValue(dec).Kind() != KindInvalid || dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+}
+
+func TestValueUntagged(t *testing.T) {
+	u := &user{"John", 30, time.Unix(1e9, 0)}
+	enc := NewEnc()
+	enc.Uint64(2)
+	enc.Marshaler(u)
+	enc.Marshaler(u)
+	enc.ByteSlice([]byte("hostname"))
+	data := enc.Bytes()
+
+	dec := NewDec(data)
+	v := DecodeValue(dec)
+	if dec.Error() != nil || v.Len() != 4 || v.Index(0).Uint() != 2 {
+		t.Fatalf("decoding failed: %v %v", v, dec.Error())
+	}
+	n := v.Index(1)
+	if n.Kind() != KindNested || n.Index(0).Str() != "John" || n.Index(1).Int() != 30 || n.Index(2).Kind() != KindBytes {
+		t.Errorf("unexpected nested: %v", n)
+	}
+	if v.Index(3).Kind() != KindBytes || v.Index(3).Str() != "hostname" {
+		t.Errorf("unexpected bytes: %v", v.Index(3))
+	}
+	re := NewEnc()
+	EncodeValue(re, v)
+	if !bytes.Equal(re.Bytes(), data) {
+		t.Errorf("expected: %v and got: %v", data, re.Bytes())
+	}
+	var x Value
+	NewDec(re.Bytes()[2:]).Unmarshaler(&x)
+	if !x.Equal(n) {
+		t.Errorf("expected: %v and got: %v", n, x)
+	}
+
+	dec = NewDec([]byte{1, 200})
+	if DecodeValue(dec); dec.Error() != errDecode {
+		t.Errorf("expected: %v and got: %v", errDecode, dec.Error())
+	}
+}
+
+func TestValueDeep(t *testing.T) {
+	for _, tagged := range []bool{false, true} {
+		data := []byte("leaf")
+		for i := 0; i < 2*maxValueDepth; i++ {
+			enc := NewEnc()
+			enc.SetTagged(tagged)
+			enc.Uint64(uint64(i))
+			enc.ByteSlice(data)
+			data = enc.Bytes()
+		}
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		alloc := ms.TotalAlloc
+		var v Value
+		v.UnmarshalBinary(data)
+		runtime.ReadMemStats(&ms)
+		// work is bounded by a multiple of input size rather than its square
+		if n := ms.TotalAlloc - alloc; n > uint64(16*(valueWorkFactor*len(data)+valueWorkMin)) {
+			t.Errorf("%v bytes of %v bytes input allocated", n, len(data))
+		}
+		if b, err := v.MarshalBinary(); err != nil || !bytes.Equal(b, data) {
+			t.Errorf("expected: round trip and got: %v", err)
+		}
+	}
+
+	//non-minimal varint does not parse as untagged message
+	var v Value
+	v.UnmarshalBinary([]byte{2, 0x81, 0x00})
+	if v.Len() != 0 || !bytes.Equal(v.Bytes(), []byte{2, 0x81, 0x00}) {
+		t.Errorf("expected: raw payload and got: %v", v)
+	}
+}
+
+type user struct {
+	name       string
+	age        int
+	registered time.Time
+}
+
+func (u *user) MarshalBinary() ([]byte, error) {
+	enc := NewEnc()
+	enc.ByteSlice([]byte(u.name))
+	enc.Int64(int64(u.age))
+	enc.Marshaler(u.registered)
+	return enc.Bytes(), enc.Error()
+}